@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -22,6 +23,7 @@ import (
 const (
 	name     = "comodo"
 	category = "av"
+	config   = "/opt/COMODO/etc/COMODO.xml"
 )
 
 var (
@@ -33,10 +35,19 @@ type ResultsData struct {
 	Infected bool   `json:"infected" structs:"infected"`
 	Result   string `json:"result" structs:"result"`
 	Engine   string `json:"engine" structs:"engine"`
+	Database string `json:"database" structs:"database"`
+	BaseDate string `json:"base_date" structs:"base_date"`
 	Updated  string `json:"updated" structs:"updated"`
 	Error    string `json:"error" structs:"error"`
 }
 
+// comodoVersion holds the product and signature base versions from COMODO.xml
+type comodoVersion struct {
+	Product  string
+	Database string
+	Date     string
+}
+
 // Comodo json object
 type Comodo struct {
 	Results ResultsData `json:"analysis" structs:"analysis"`
@@ -62,9 +73,14 @@ func AvScan(timeout int) Comodo {
 // ParseComodoOutput convert comodo output into ResultsData struct
 func ParseComodoOutput(comodoout string, err error) ResultsData {
 
+	version := getComodoVersion()
+
 	comodo := ResultsData{
 		Infected: false,
-		Engine:   getComodoVersion(),
+		Engine:   version.Product,
+		Database: version.Database,
+		BaseDate: version.Date,
+		Updated:  getUpdatedDate(),
 		Error:    "nil",
 	}
 
@@ -126,20 +142,71 @@ func updateAV() error {
 	return err
 }
 
-func getComodoVersion() string {
-	file, _ := os.Open("/opt/COMODO/etc/COMODO.xml")
+// getComodoVersion get Anti-Virus product and signature base versions
+func getComodoVersion() comodoVersion {
 
+	file, err := os.Open(config)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while opening COMODO.xml"))
+		return comodoVersion{Product: "version error"}
+	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "<ProductVersion>") {
-			versionOut := strings.TrimSpace(strings.Replace(strings.Replace(line, "<ProductVersion>", "", 1), "</ProductVersion>", "", 1))
-			return versionOut
+	version, err := parseComodoVersion(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing COMODO.xml"))
+		return comodoVersion{Product: "version error"}
+	}
+
+	return version
+}
+
+// parseComodoVersion reads the version elements from COMODO.xml wherever they are nested
+func parseComodoVersion(r io.Reader) (version comodoVersion, err error) {
+
+	decoder := xml.NewDecoder(r)
+	// COMODO.xml only holds ASCII values, so any declared charset can be read as is
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var element string
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return version, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element = t.Name.Local
+		case xml.EndElement:
+			element = ""
+		case xml.CharData:
+			value := strings.TrimSpace(string(t))
+			if len(value) == 0 {
+				continue
+			}
+			switch element {
+			case "ProductVersion":
+				version.Product = value
+			case "BaseVer":
+				version.Database = value
+			case "BaseDate":
+				version.Date = value
+			}
 		}
 	}
-	return "version error"
+
+	if len(version.Product) == 0 {
+		return version, errors.New("ProductVersion not found")
+	}
+
+	return version, nil
 }
 
 func getUpdatedDate() string {
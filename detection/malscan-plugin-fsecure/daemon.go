@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-fsecure/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	fsavd        = "/opt/f-secure/fsav/bin/fsavd"
+	daemonStatus = "/var/log/malscan/fsavd.json"
+	daemonLock   = "/var/run/malscan-fsavd.lock"
+)
+
+// DaemonStatus json object describing the fsavd daemon
+type DaemonStatus struct {
+	Running    bool   `json:"running" structs:"running"`
+	Healthy    bool   `json:"healthy" structs:"healthy"`
+	PID        int    `json:"pid" structs:"pid"`
+	Restarts   int    `json:"restarts" structs:"restarts"`
+	Started    string `json:"started" structs:"started"`
+	Checked    string `json:"checked" structs:"checked"`
+	Supervised bool   `json:"supervised" structs:"supervised"`
+	Error      string `json:"error" structs:"error"`
+}
+
+// Supervisor keeps a single fsavd running and restarts it with backoff when it fails
+type Supervisor struct {
+	Interval   time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// StartTimeout bounds how long Ensure waits for fsavd, it cannot extend the
+	// caller's deadline, and is how long a launched fsavd may take to load its
+	// databases before it is restarted
+	StartTimeout time.Duration
+
+	restarts int
+	started  string
+}
+
+// NewSupervisor returns a supervisor with the plugin's default timings
+func NewSupervisor() *Supervisor {
+	return &Supervisor{
+		Interval:     30 * time.Second,
+		MinBackoff:   time.Second,
+		MaxBackoff:   time.Minute,
+		StartTimeout: time.Minute,
+	}
+}
+
+// Ensure makes sure fsavd is running and healthy, starting it only if it is not
+func (s *Supervisor) Ensure(ctx context.Context) (DaemonStatus, error) {
+
+	ctx, cancel := context.WithTimeout(ctx, s.StartTimeout)
+	defer cancel()
+
+	status := checkDaemon(ctx)
+	backoff := s.MinBackoff
+
+	for !status.Healthy {
+		if err := s.start(ctx, status); err != nil {
+			log.Debug(errors.Wrap(err, "Error while starting fsavd"))
+		}
+
+		select {
+		case <-ctx.Done():
+			return s.withState(status), errors.Wrap(ctx.Err(), "fsavd did not become healthy")
+		case <-time.After(backoff):
+		}
+
+		status = checkDaemon(ctx)
+
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+
+	return s.withState(status), nil
+}
+
+// Run health-checks fsavd every interval until ctx is done, restarting it when it fails
+func (s *Supervisor) Run(ctx context.Context) error {
+
+	if previous, err := readDaemonStatus(); err == nil {
+		s.restarts = previous.Restarts
+		s.started = previous.Started
+	}
+
+	for {
+		status, err := s.Ensure(ctx)
+		if err != nil {
+			status.Error = err.Error()
+		}
+		status.Supervised = ctx.Err() == nil
+		writeDaemonStatus(status)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.Interval):
+		}
+	}
+}
+
+// start kills an unresponsive fsavd if there is one and launches a new one, the
+// supervisor daemon and scans share a lock so only one of them does so
+func (s *Supervisor) start(ctx context.Context, status DaemonStatus) error {
+
+	unlock, err := lockDaemon()
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while locking fsavd startup"))
+	} else {
+		defer unlock()
+	}
+
+	// another process restarted it while we waited for the lock
+	if pid := findDaemon(); pid != 0 && pid != status.PID {
+		return nil
+	}
+
+	if status.Running {
+		// a freshly launched fsavd is not healthy until its databases are loaded
+		if launch, err := readLaunch(); err == nil && launch.PID == status.PID && time.Since(launch.Launched) < s.StartTimeout {
+			return nil
+		}
+		if process, err := os.FindProcess(status.PID); err == nil {
+			process.Kill()
+		}
+	}
+	if status.Running || len(s.started) != 0 {
+		s.restarts++
+	}
+
+	launched := time.Now()
+	s.started = launched.Format(time.RFC3339)
+
+	// fsavd forks into the background, so the command returns once it is launched
+	if _, err = utils.RunCommand(ctx, fsavd); err != nil {
+		return err
+	}
+
+	if pid := findDaemon(); pid != 0 {
+		writeLaunch(daemonLaunch{PID: pid, Launched: launched})
+	}
+	return nil
+}
+
+// daemonLaunch records the fsavd last launched, kept in the lock file so the
+// supervisor daemon and scans see each other's launches
+type daemonLaunch struct {
+	PID      int       `json:"pid"`
+	Launched time.Time `json:"launched"`
+}
+
+func readLaunch() (launch daemonLaunch, err error) {
+	data, err := ioutil.ReadFile(daemonLock)
+	if err != nil {
+		return launch, err
+	}
+	err = json.Unmarshal(data, &launch)
+	return launch, err
+}
+
+func writeLaunch(launch daemonLaunch) {
+	launchJSON, _ := json.Marshal(launch)
+	if err := ioutil.WriteFile(daemonLock, launchJSON, 0644); err != nil {
+		log.Debug(errors.Wrap(err, "Error while recording fsavd launch"))
+	}
+}
+
+// lockDaemon takes the fsavd startup lock, returning the function that releases it
+func lockDaemon() (func(), error) {
+
+	f, err := os.OpenFile(daemonLock, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+func (s *Supervisor) withState(status DaemonStatus) DaemonStatus {
+	status.Restarts = s.restarts
+	status.Started = s.started
+	return status
+}
+
+// checkDaemon finds the running fsavd and checks that fsav can talk to it
+func checkDaemon(ctx context.Context) DaemonStatus {
+
+	status := DaemonStatus{
+		Checked: time.Now().Format(time.RFC3339),
+		Error:   "nil",
+	}
+
+	status.PID = findDaemon()
+	status.Running = status.PID != 0
+	if !status.Running {
+		status.Error = "fsavd is not running"
+		return status
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := utils.RunCommand(checkCtx, "/opt/f-secure/fsav/bin/fsav", "--version"); err != nil {
+		status.Error = fmt.Sprintf("fsav cannot reach fsavd: %s", err)
+		return status
+	}
+
+	status.Healthy = true
+	return status
+}
+
+// findDaemon returns the pid of the running fsavd or 0
+func findDaemon() int {
+
+	comms, _ := filepath.Glob("/proc/[0-9]*/comm")
+	for _, comm := range comms {
+		data, err := ioutil.ReadFile(comm)
+		if err != nil || strings.TrimSpace(string(data)) != "fsavd" {
+			continue
+		}
+		pid, err := strconv.Atoi(filepath.Base(filepath.Dir(comm)))
+		if err == nil {
+			return pid
+		}
+	}
+
+	return 0
+}
+
+func writeDaemonStatus(status DaemonStatus) {
+	statusJSON, _ := json.Marshal(status)
+	if err := ioutil.WriteFile(daemonStatus, statusJSON, 0644); err != nil {
+		log.Debug(errors.Wrap(err, "Error while writing fsavd status"))
+	}
+}
+
+func readDaemonStatus() (status DaemonStatus, err error) {
+	data, err := ioutil.ReadFile(daemonStatus)
+	if err != nil {
+		return status, err
+	}
+	err = json.Unmarshal(data, &status)
+	return status, err
+}
+
+// daemonHealth checks fsavd now and adds what the supervisor last recorded
+func daemonHealth(ctx context.Context) DaemonStatus {
+
+	status := checkDaemon(ctx)
+
+	if previous, err := readDaemonStatus(); err == nil {
+		status.Restarts = previous.Restarts
+		status.Started = previous.Started
+		status.Supervised = previous.Supervised
+	}
+
+	return status
+}
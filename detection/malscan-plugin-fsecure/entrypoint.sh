@@ -0,0 +1,7 @@
+#!/bin/bash
+
+# supervise fsavd for as long as the container runs, it records its status in
+# /var/log/malscan/fsavd.json for the health command
+/bin/avscan daemon > /dev/null 2>&1 &
+
+exec /bin/avscan "$@"
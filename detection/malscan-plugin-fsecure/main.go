@@ -1,15 +1,15 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/LiamHellend/malscan-plugin-fsecure/utils"
@@ -19,8 +19,10 @@ import (
 )
 
 const (
-	name     = "fsecure"
-	category = "av"
+	name          = "fsecure"
+	category      = "av"
+	updateScript  = "/opt/malscan/update"
+	updatePackage = "/opt/f-secure/fsdbupdate9.run"
 )
 
 var (
@@ -43,6 +45,14 @@ type ScanEngines struct {
 	Aquarius string `json:"aquarius" structs:"aquarius"`
 }
 
+// Import json object, the outcome of an offline database update
+type Import struct {
+	Source string `json:"source" structs:"source"`
+	Before string `json:"before" structs:"before"`
+	After  string `json:"after" structs:"after"`
+	Error  string `json:"error" structs:"error"`
+}
+
 // FSecure json object
 type FSecure struct {
 	Results ResultsData `json:"analysis" structs:"analysis"`
@@ -54,6 +64,11 @@ func AvScan(timeout int) FSecure {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
+	supervisor := NewSupervisor()
+	if _, err := supervisor.Ensure(ctx); err != nil {
+		log.Debug(errors.Wrap(err, "Error while starting fsavd"))
+	}
+
 	results, err := utils.RunCommand(
 		ctx,
 		"/opt/f-secure/fsav/bin/fsav",
@@ -61,18 +76,22 @@ func AvScan(timeout int) FSecure {
 		path,
 	)
 
-	if err != nil && err.Error() != "exit status 3" {
-		// If fails try a second time
+	// Only retry if the failure was caused by fsavd going away and there is time left
+	if err != nil && err.Error() != "exit status 3" && ctx.Err() == nil && !checkDaemon(ctx).Healthy {
+		if _, err := supervisor.Ensure(ctx); err != nil {
+			log.Debug(errors.Wrap(err, "Error while restarting fsavd"))
+		}
+
 		results, err = utils.RunCommand(
 			ctx,
 			"/opt/f-secure/fsav/bin/fsav",
 			"--virus-action1=none",
 			path,
 		)
+	}
 
-		if err != nil {
-			log.Debug(errors.Wrap(err, "Error while runing scan command"))
-		}
+	if err != nil && err.Error() != "exit status 3" {
+		log.Debug(errors.Wrap(err, "Error while runing scan command"))
 	}
 
 	if err != nil {
@@ -144,7 +163,6 @@ func ParseFSecureOutput(fsecureout string, err error) ResultsData {
 // getFSecureVersion get Anti-Virus scanner version
 func getFSecureVersion() (version string, database string) {
 
-	exec.Command("/opt/f-secure/fsav/bin/fsavd").Output()
 	versionOut, _ := utils.RunCommand(nil, "/opt/f-secure/fsav/bin/fsav", "--version")
 
 	return parseFSecureVersion(versionOut)
@@ -191,34 +209,13 @@ func getUpdatedDate() string {
 
 func updateAV(ctx context.Context) error {
 
-	var out string
-
-	for i := 1; i != 3; i++ {
-
-		cmd := exec.Command("sh", "/opt/malscan/update")
-		cmdReader, err := cmd.StdoutPipe()
-		scanner := bufio.NewScanner(cmdReader)
-		go func() {
-			for scanner.Scan() {
-				out += fmt.Sprintln("\n", scanner.Text())
-			}
-		}()
-		err = cmd.Start()
-		if err != nil {
-			log.Debug(errors.Wrap(err, "Error starting update command"))
-			continue
-		}
-		err = cmd.Wait()
-		if err != nil {
-			log.Debug(errors.Wrap(err, "Error waiting for update command"))
-			continue
-		}
-
-		break
-
+	out, err := runUpdate(ctx, updatePackage)
+	if ctx.Err() != nil {
+		fmt.Println(1)
+		return ctx.Err()
 	}
 
-	if strings.Contains(out, "All done.") {
+	if err == nil && strings.Contains(out, "All done.") {
 		fmt.Println(0)
 	} else {
 		fmt.Println(1)
@@ -226,10 +223,68 @@ func updateAV(ctx context.Context) error {
 
 	// Update UPDATED file
 	t := time.Now().Format("20060102")
-	err := ioutil.WriteFile("/var/log/malscan/updated.log", []byte(t), 0644)
+	err = ioutil.WriteFile("/var/log/malscan/updated.log", []byte(t), 0644)
 	return err
 }
 
+// importAV applies a locally supplied fsdbupdate9.run and reports the database version it installed
+func importAV(ctx context.Context, pkg string) error {
+
+	if _, err := NewSupervisor().Ensure(ctx); err != nil {
+		log.Debug(errors.Wrap(err, "Error while starting fsavd"))
+	}
+
+	_, before := getFSecureVersion()
+
+	result := Import{Source: pkg, Before: before, Error: "nil"}
+
+	info, err := os.Stat(pkg)
+	if err == nil && !info.Mode().IsRegular() {
+		err = fmt.Errorf("%s is not a regular file", pkg)
+	}
+	if err == nil {
+		var out string
+		out, err = runUpdate(ctx, pkg)
+		if err == nil && !strings.Contains(out, "All done.") {
+			err = errors.New("dbupdate did not complete")
+		}
+		log.Debug(out)
+	}
+	if err == nil {
+		_, result.After = getFSecureVersion()
+	}
+
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while importing database"))
+		result.Error = err.Error()
+	}
+
+	importJSON, _ := json.Marshal(result)
+	fmt.Println(string(importJSON))
+
+	if err != nil {
+		return err
+	}
+
+	// Update UPDATED file
+	t := time.Now().Format("20060102")
+	return ioutil.WriteFile("/var/log/malscan/updated.log", []byte(t), 0644)
+}
+
+// runUpdate runs the update script against pkg, trying a second time if it fails
+func runUpdate(ctx context.Context, pkg string) (out string, err error) {
+
+	for i := 1; i != 3; i++ {
+		out, err = utils.RunCommand(ctx, "sh", updateScript, pkg)
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+		log.Debug(errors.Wrap(err, "Error while running update command"))
+	}
+
+	return out, err
+}
+
 func main() {
 
 	cli.AppHelpTemplate = utils.AppHelpTemplate
@@ -255,6 +310,12 @@ func main() {
 			Name:    "update",
 			Aliases: []string{"u"},
 			Usage:   "Update virus definitions",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "apply a locally supplied fsdbupdate9.run",
+				},
+			},
 			Action: func(c *cli.Context) error {
 				ctx, cancel := context.WithTimeout(
 					context.Background(),
@@ -262,9 +323,53 @@ func main() {
 				)
 				defer cancel()
 
+				if c.IsSet("from") {
+					return importAV(ctx, c.String("from"))
+				}
+
 				return updateAV(ctx)
 			},
 		},
+		{
+			Name:  "health",
+			Usage: "Check the fsavd daemon",
+			Action: func(c *cli.Context) error {
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+
+				healthJSON, _ := json.Marshal(daemonHealth(ctx))
+				fmt.Println(string(healthJSON))
+
+				return nil
+			},
+		},
+		{
+			Name:  "daemon",
+			Usage: "Supervise the fsavd daemon, restarting it when it fails",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "interval",
+					Value: 30,
+					Usage: "health check interval (in seconds)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				signals := make(chan os.Signal, 1)
+				signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+				go func() {
+					<-signals
+					cancel()
+				}()
+
+				supervisor := NewSupervisor()
+				supervisor.Interval = time.Duration(c.Int("interval")) * time.Second
+
+				return supervisor.Run(ctx)
+			},
+		},
 	}
 	app.Action = func(c *cli.Context) error {
 
@@ -2,4 +2,4 @@
 
 /etc/init.d/fsaua start
 /etc/init.d/fsupdate start
-/opt/f-secure/fsav/bin/dbupdate /opt/f-secure/fsdbupdate9.run; exit 0
+exec /opt/f-secure/fsav/bin/dbupdate "${1:-/opt/f-secure/fsdbupdate9.run}"
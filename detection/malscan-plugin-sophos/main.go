@@ -7,6 +7,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,6 +26,12 @@ const (
 
 var (
 	path string
+
+	detectionLine = regexp.MustCompile(`^>>> (Virus|Virus fragment|Application|Adware or PUA) '(.+)' found in file (.+)$`)
+	scannedLine   = regexp.MustCompile(`^(\d+|No) files? scanned`)
+	errorsLine    = regexp.MustCompile(`^(\d+|No) errors? (?:was|were) encountered`)
+	virusesLine   = regexp.MustCompile(`^(\d+|No) (?:virus|viruses|virus fragments?) (?:was|were) discovered`)
+	infectedLine  = regexp.MustCompile(`^(\d+|No) files? out of (\d+) (?:was|were) infected`)
 )
 
 // Sophos json object
@@ -39,30 +47,67 @@ type ResultsData struct {
 	Database string `json:"database" structs:"database"`
 	Updated  string `json:"updated" structs:"updated"`
 	Error    string `json:"error" structs:"error"`
+
+	Detections []Detection `json:"detections,omitempty" structs:"detections"`
+	Summary    Summary     `json:"summary" structs:"summary"`
+	ScanErrors []string    `json:"scan_errors,omitempty" structs:"scan_errors"`
+	Partial    bool        `json:"partial" structs:"partial"`
+	PUA        bool        `json:"pua" structs:"pua"`
+	Options    ScanOptions `json:"options" structs:"options"`
+}
+
+// Detection json object, one per threat reported by savscan
+type Detection struct {
+	File   string `json:"file" structs:"file"`
+	Member string `json:"member,omitempty" structs:"member"`
+	Threat string `json:"threat" structs:"threat"`
+	Type   string `json:"type" structs:"type"`
+}
+
+// Summary json object holding the savscan summary counts
+type Summary struct {
+	Scanned  int `json:"scanned" structs:"scanned"`
+	Infected int `json:"infected" structs:"infected"`
+	Viruses  int `json:"viruses" structs:"viruses"`
+	Errors   int `json:"errors" structs:"errors"`
 }
 
 // AvScan performs antivirus scan
-func AvScan(timeout int) Sophos {
+func AvScan(timeout int, options ScanOptions) Sophos {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
 	var results ResultsData
 
-	output, err := utils.RunCommand(ctx, "/opt/sophos/bin/savscan", "-f", "-ss", path)
-	if err != nil && err.Error() != "exit status 3" {
-		output, err = utils.RunCommand(ctx, "/opt/sophos/bin/savscan", "-f", "-ss", path)
+	args := append(options.args(), path)
+
+	output, err := utils.RunCommand(ctx, "/opt/sophos/bin/savscan", args...)
+	if err != nil && !scanReported(err) {
+		output, err = utils.RunCommand(ctx, "/opt/sophos/bin/savscan", args...)
 		if err != nil {
 			log.Debug(errors.Wrap(err, "Error while trying to run scan command"))
 		}
 	}
 
 	results = ParseSophosOutput(output, err)
+	results.Options = options
 
 	return Sophos{
 		Results: results,
 	}
 }
 
+// scanReported reports whether savscan produced output worth parsing, savscan
+// exits with status 2 if an error stopped it part way and status 3 if it found a virus
+func scanReported(err error) bool {
+	return err == nil || scanAborted(err) || err.Error() == "exit status 3"
+}
+
+// scanAborted reports whether an error stopped savscan before it scanned everything
+func scanAborted(err error) bool {
+	return err != nil && err.Error() == "exit status 2"
+}
+
 // ParseSophosOutput convert sophos output into ResultsData struct
 func ParseSophosOutput(sophosout string, err error) ResultsData {
 
@@ -76,7 +121,7 @@ func ParseSophosOutput(sophosout string, err error) ResultsData {
 		Error:    "nil",
 	}
 
-	if err != nil && err.Error() != "exit status 3" {
+	if !scanReported(err) {
 		sophosResults.Error = err.Error()
 		return sophosResults
 	}
@@ -84,16 +129,111 @@ func ParseSophosOutput(sophosout string, err error) ResultsData {
 	lines := strings.Split(sophosout, "\n")
 
 	for _, line := range lines {
-		if strings.Contains(line, ">>> Virus") && strings.Contains(line, "found in file") {
-			parts := strings.Split(line, "'")
-			sophosResults.Result = strings.TrimSpace(parts[1])
+		line = strings.TrimSpace(line)
+
+		if match := detectionLine.FindStringSubmatch(line); match != nil {
+			file, member := splitArchiveMember(strings.TrimSpace(match[3]))
+			sophosResults.Detections = append(sophosResults.Detections, Detection{
+				File:   file,
+				Member: member,
+				Threat: strings.TrimSpace(match[2]),
+				Type:   detectionType(match[1]),
+			})
+			continue
+		}
+
+		if strings.HasPrefix(line, "Could not ") {
+			sophosResults.ScanErrors = append(sophosResults.ScanErrors, line)
+			continue
+		}
+
+		if match := scannedLine.FindStringSubmatch(line); match != nil {
+			sophosResults.Summary.Scanned = parseCount(match[1])
+		}
+		if match := errorsLine.FindStringSubmatch(line); match != nil {
+			sophosResults.Summary.Errors = parseCount(match[1])
+		}
+		if match := virusesLine.FindStringSubmatch(line); match != nil {
+			sophosResults.Summary.Viruses = parseCount(match[1])
+		}
+		if match := infectedLine.FindStringSubmatch(line); match != nil {
+			sophosResults.Summary.Infected = parseCount(match[1])
+			sophosResults.Summary.Scanned = parseCount(match[2])
+		}
+	}
+
+	// Adware and PUA are reported apart from viruses and do not mark the file infected
+	for _, detection := range sophosResults.Detections {
+		if detection.Type == "pua" {
+			sophosResults.PUA = true
+			continue
+		}
+		if !sophosResults.Infected {
 			sophosResults.Infected = true
+			sophosResults.Result = detection.Threat
 		}
 	}
 
+	// Files savscan could not open are unscanned, and an aborted run may not have
+	// reached them at all, so the result must not read as clean
+	errorCount := sophosResults.Summary.Errors
+	if len(sophosResults.ScanErrors) > errorCount {
+		errorCount = len(sophosResults.ScanErrors)
+	}
+	switch {
+	case scanAborted(err) && errorCount == 0:
+		sophosResults.Partial = true
+		sophosResults.Error = "partial scan: savscan stopped on an error"
+	case scanAborted(err):
+		sophosResults.Partial = true
+		sophosResults.Error = fmt.Sprintf("partial scan: savscan stopped on an error, %d error(s) encountered", errorCount)
+	case errorCount != 0:
+		sophosResults.Partial = true
+		sophosResults.Error = fmt.Sprintf("partial scan: %d error(s) encountered", errorCount)
+	}
+
 	return sophosResults
 }
 
+// detectionType maps the savscan detection kind to virus or pua
+func detectionType(kind string) string {
+	switch kind {
+	case "Application", "Adware or PUA":
+		return "pua"
+	}
+	return "virus"
+}
+
+// parseCount converts a savscan summary count, where "No" means zero
+func parseCount(count string) int {
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// splitArchiveMember splits a reported path into the file on disk and the
+// archive member savscan appends to it with "/" separators
+func splitArchiveMember(reported string) (file string, member string) {
+
+	file = reported
+	for {
+		if info, err := os.Stat(file); err == nil {
+			if info.IsDir() || file == reported {
+				return reported, ""
+			}
+			return file, strings.TrimPrefix(reported, file+"/")
+		}
+
+		parent := filepath.Dir(file)
+		if parent == file {
+			return reported, ""
+		}
+		file = parent
+	}
+}
+
 // Get Anti-Virus scanner version
 func getSophosVersion() (version string, database string) {
 
@@ -193,11 +333,18 @@ func main() {
 			EnvVar: "MALSCAN_TIMEOUT",
 		},
 	}
+	app.Flags = append(app.Flags, optionFlags...)
 	app.Commands = []cli.Command{
 		{
 			Name:    "update",
 			Aliases: []string{"u"},
 			Usage:   "Update virus definitions",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "install IDE/VDL packages from a local directory or tarball",
+				},
+			},
 			Action: func(c *cli.Context) error {
 				ctx, cancel := context.WithTimeout(
 					context.Background(),
@@ -205,6 +352,10 @@ func main() {
 				)
 				defer cancel()
 
+				if c.IsSet("from") {
+					return importAV(ctx, c.String("from"))
+				}
+
 				return updateAV(ctx)
 			},
 		},
@@ -218,7 +369,12 @@ func main() {
 		if c.Args().Present() {
 			path, _ = filepath.Abs(c.Args().First())
 
-			sophos := AvScan(c.Int("timeout"))
+			options, err := loadScanOptions(c)
+			if err != nil {
+				return err
+			}
+
+			sophos := AvScan(c.Int("timeout"), options)
 
 			// convert to JSON
 			sophosJSON, _ := json.Marshal(sophos)
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const ideDir = "/opt/sophos/lib/sav"
+
+// Import json object, the outcome of an offline definitions import
+type Import struct {
+	Source    string   `json:"source" structs:"source"`
+	Installed []string `json:"installed" structs:"installed"`
+	Before    string   `json:"before" structs:"before"`
+	After     string   `json:"after" structs:"after"`
+	Error     string   `json:"error" structs:"error"`
+}
+
+// importAV installs IDE/VDL packages from a local directory or tarball and
+// checks with savscan that the virus data version advanced, putting the
+// previous definitions back when it did not
+func importAV(ctx context.Context, source string) error {
+
+	_, before := getSophosVersion()
+
+	result := Import{Source: source, Before: before, Error: "nil"}
+
+	err := func() error {
+		// stage next to the IDE directory so the swap is a rename
+		staging, err := ioutil.TempDir(ideDir, ".import")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(staging)
+
+		result.Installed, err = installPackages(ctx, source, staging)
+		if err != nil {
+			return err
+		}
+
+		backup, err := ioutil.TempDir(ideDir, ".backup")
+		if err != nil {
+			return err
+		}
+
+		swapped, err := swapPackages(staging, backup, result.Installed)
+		if err == nil {
+			_, result.After = getSophosVersion()
+			if compareVersions(result.After, result.Before) <= 0 {
+				err = fmt.Errorf("virus data version did not advance from %q", result.Before)
+			}
+		}
+
+		if err != nil {
+			// the backup holds the only copy of the previous definitions until
+			// they are restored
+			if rerr := restorePackages(backup, swapped); rerr != nil {
+				return errors.Wrap(rerr, err.Error())
+			}
+			if len(result.After) != 0 {
+				_, result.After = getSophosVersion()
+			}
+		}
+
+		os.RemoveAll(backup)
+		return err
+	}()
+
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while importing definitions"))
+		result.Error = err.Error()
+	}
+
+	importJSON, _ := json.Marshal(result)
+	fmt.Println(string(importJSON))
+
+	if err != nil {
+		return err
+	}
+
+	// Update updated.log file
+	t := time.Now().Format("20060102")
+	err = ioutil.WriteFile("/var/log/malscan/updated.log", []byte(t), 0644)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while writing to updated.log"))
+	}
+	return err
+}
+
+// swapPackages moves the staged files into the IDE directory once every one of
+// them is written, moving the files they replace into backup, and returns the
+// files it swapped
+func swapPackages(staging string, backup string, installed []string) ([]string, error) {
+
+	var swapped []string
+	for _, name := range unique(installed) {
+		dst := filepath.Join(ideDir, name)
+		if _, err := os.Stat(dst); err == nil {
+			if err = os.Rename(dst, filepath.Join(backup, name)); err != nil {
+				return swapped, err
+			}
+		} else if !os.IsNotExist(err) {
+			return swapped, err
+		}
+		swapped = append(swapped, name)
+		if err := os.Rename(filepath.Join(staging, name), dst); err != nil {
+			return swapped, err
+		}
+	}
+
+	return swapped, nil
+}
+
+// restorePackages removes the imported files from the IDE directory and moves
+// the backed up files back in their place
+func restorePackages(backup string, swapped []string) error {
+
+	var errs []string
+	for _, name := range swapped {
+		dst := filepath.Join(ideDir, name)
+		saved := filepath.Join(backup, name)
+		if _, err := os.Stat(saved); err == nil {
+			err = os.Rename(saved, dst)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			continue
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) != 0 {
+		return fmt.Errorf("failed to restore previous definitions, they are kept in %s: %s", backup, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// installPackages copies every definition file found in source into dir
+func installPackages(ctx context.Context, source string, dir string) ([]string, error) {
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return installFromDir(ctx, source, dir)
+	}
+
+	switch {
+	case strings.HasSuffix(source, ".zip"):
+		return installFromZip(ctx, source, dir)
+	case strings.HasSuffix(source, ".tar"), strings.HasSuffix(source, ".tgz"), strings.HasSuffix(source, ".tar.gz"):
+		return installFromTar(ctx, source, dir)
+	}
+
+	return nil, fmt.Errorf("unsupported definitions package %s", source)
+}
+
+func installFromDir(ctx context.Context, source string, dir string) (installed []string, err error) {
+
+	err = filepath.Walk(source, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isDefinitionFile(info.Name()) {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = installFile(ctx, dir, info.Name(), f); err != nil {
+			return err
+		}
+		installed = append(installed, info.Name())
+		return nil
+	})
+
+	return installed, checkInstalled(installed, err)
+}
+
+func installFromTar(ctx context.Context, tarball string, dir string) (installed []string, err error) {
+
+	f, err := os.Open(tarball)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(tarball, ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return installed, err
+		}
+
+		name := filepath.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || !isDefinitionFile(name) {
+			continue
+		}
+
+		if err = installFile(ctx, dir, name, tr); err != nil {
+			return installed, err
+		}
+		installed = append(installed, name)
+	}
+
+	return installed, checkInstalled(installed, nil)
+}
+
+func installFromZip(ctx context.Context, archive string, dir string) (installed []string, err error) {
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	for _, file := range zr.File {
+		name := filepath.Base(file.Name)
+		if file.FileInfo().IsDir() || !isDefinitionFile(name) {
+			continue
+		}
+
+		rc, err := file.Open()
+		if err != nil {
+			return installed, err
+		}
+		err = installFile(ctx, dir, name, rc)
+		rc.Close()
+		if err != nil {
+			return installed, err
+		}
+		installed = append(installed, name)
+	}
+
+	return installed, checkInstalled(installed, nil)
+}
+
+// installFile writes a definition file into the staging directory dir
+func installFile(ctx context.Context, dir string, name string, r io.Reader) error {
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return errors.Wrapf(err, "Error while copying %s", name)
+	}
+	return f.Close()
+}
+
+func checkInstalled(installed []string, err error) error {
+	if err == nil && len(installed) == 0 {
+		return errors.New("no IDE or VDL files found")
+	}
+	return err
+}
+
+func unique(names []string) []string {
+	seen := map[string]bool{}
+	var list []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// isDefinitionFile reports whether name is an IDE or VDL definitions file
+func isDefinitionFile(name string) bool {
+	name = strings.ToLower(name)
+	switch filepath.Ext(name) {
+	case ".ide":
+		return true
+	case ".vdb", ".dat":
+		return strings.HasPrefix(name, "vdl")
+	}
+	return false
+}
+
+// compareVersions compares dotted numeric versions such as "5.78"
+func compareVersions(a, b string) int {
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var x, y int
+		if i < len(aParts) {
+			x, _ = strconv.Atoi(strings.TrimSpace(aParts[i]))
+		}
+		if i < len(bParts) {
+			y, _ = strconv.Atoi(strings.TrimSpace(bParts[i]))
+		}
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+
+	return 0
+}
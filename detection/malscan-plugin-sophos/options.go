@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const defaultConfig = "/etc/malscan/sophos.json"
+
+// ScanOptions json object, the savscan options a scan runs with
+type ScanOptions struct {
+	Archive      bool     `json:"archive" structs:"archive"`
+	ArchiveDepth int      `json:"archive_depth" structs:"archive_depth"`
+	Mime         bool     `json:"mime" structs:"mime"`
+	PUA          bool     `json:"pua" structs:"pua"`
+	Suspicious   bool     `json:"suspicious" structs:"suspicious"`
+	Exclude      []string `json:"exclude,omitempty" structs:"exclude"`
+}
+
+// args converts the options into savscan command line arguments
+func (o ScanOptions) args() []string {
+
+	args := []string{"-f", "-s"}
+
+	if o.Archive || o.ArchiveDepth > 0 {
+		args = append(args, "-archive")
+	}
+	if o.ArchiveDepth > 0 {
+		args = append(args, "--max-recursion-depth="+strconv.Itoa(o.ArchiveDepth))
+	}
+	if o.Mime {
+		args = append(args, "-mime")
+	}
+	if o.PUA {
+		args = append(args, "-pua")
+	}
+	if o.Suspicious {
+		args = append(args, "-suspicious")
+	}
+	if len(o.Exclude) != 0 {
+		// savscan treats every path after -exclude as excluded until -include
+		args = append(args, "-exclude")
+		args = append(args, o.Exclude...)
+		args = append(args, "-include")
+	}
+
+	return args
+}
+
+// optionFlags are the global flags that set scan options
+var optionFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:   "config",
+		Value:  defaultConfig,
+		Usage:  "savscan options config file (json)",
+		EnvVar: "MALSCAN_SOPHOS_CONFIG",
+	},
+	cli.BoolFlag{
+		Name:   "archive",
+		Usage:  "scan inside archives and installers",
+		EnvVar: "MALSCAN_SOPHOS_ARCHIVE",
+	},
+	cli.IntFlag{
+		Name:   "archive-depth",
+		Usage:  "maximum archive recursion depth, enables archive scanning",
+		EnvVar: "MALSCAN_SOPHOS_ARCHIVE_DEPTH",
+	},
+	cli.BoolFlag{
+		Name:   "mime",
+		Usage:  "scan inside MIME encoded files",
+		EnvVar: "MALSCAN_SOPHOS_MIME",
+	},
+	cli.BoolFlag{
+		Name:   "pua",
+		Usage:  "detect adware and potentially unwanted applications",
+		EnvVar: "MALSCAN_SOPHOS_PUA",
+	},
+	cli.BoolFlag{
+		Name:   "suspicious",
+		Usage:  "detect suspicious files",
+		EnvVar: "MALSCAN_SOPHOS_SUSPICIOUS",
+	},
+	cli.StringSliceFlag{
+		Name:   "exclude",
+		Usage:  "path to exclude from the scan, may be repeated",
+		EnvVar: "MALSCAN_SOPHOS_EXCLUDE",
+	},
+}
+
+// loadScanOptions reads the config file and overrides it with any flag or env var that is set
+func loadScanOptions(c *cli.Context) (ScanOptions, error) {
+
+	options := ScanOptions{}
+
+	config := c.GlobalString("config")
+	data, err := ioutil.ReadFile(config)
+	switch {
+	case os.IsNotExist(err) && !c.GlobalIsSet("config"):
+		// the default config file is optional
+	case err != nil:
+		return options, errors.Wrapf(err, "Error while reading config file %s", config)
+	default:
+		if err = json.Unmarshal(data, &options); err != nil {
+			return options, errors.Wrapf(err, "Error while parsing config file %s", config)
+		}
+	}
+
+	if c.GlobalIsSet("archive") {
+		options.Archive = c.GlobalBool("archive")
+	}
+	if c.GlobalIsSet("archive-depth") {
+		options.ArchiveDepth = c.GlobalInt("archive-depth")
+	}
+	if c.GlobalIsSet("mime") {
+		options.Mime = c.GlobalBool("mime")
+	}
+	if c.GlobalIsSet("pua") {
+		options.PUA = c.GlobalBool("pua")
+	}
+	if c.GlobalIsSet("suspicious") {
+		options.Suspicious = c.GlobalBool("suspicious")
+	}
+	if c.GlobalIsSet("exclude") {
+		options.Exclude = c.GlobalStringSlice("exclude")
+	}
+
+	if options.ArchiveDepth > 0 {
+		options.Archive = true
+	}
+
+	return options, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// Summary - holds the capabilities aggregated by ATT&CK tactic and MBC objective
+type Summary struct {
+	Attack []TacticSummary    `json:"attack" structs:"attack"`
+	MBC    []ObjectiveSummary `json:"mbc" structs:"mbc"`
+}
+
+// TacticSummary - holds the techniques seen for one ATT&CK tactic
+type TacticSummary struct {
+	Tactic     string             `json:"tactic" structs:"tactic"`
+	Techniques []TechniqueSummary `json:"techniques" structs:"techniques"`
+}
+
+// TechniqueSummary - holds one ATT&CK technique and the rules that map to it
+type TechniqueSummary struct {
+	ID           string   `json:"id" structs:"id"`
+	Technique    string   `json:"technique" structs:"technique"`
+	Subtechnique string   `json:"subtechnique,omitempty" structs:"subtechnique"`
+	Rules        []string `json:"rules" structs:"rules"`
+}
+
+// ObjectiveSummary - holds the behaviors seen for one MBC objective
+type ObjectiveSummary struct {
+	Objective string            `json:"objective" structs:"objective"`
+	Behaviors []BehaviorSummary `json:"behaviors" structs:"behaviors"`
+}
+
+// BehaviorSummary - holds one MBC behavior and the rules that map to it
+type BehaviorSummary struct {
+	ID       string   `json:"id" structs:"id"`
+	Behavior string   `json:"behavior" structs:"behavior"`
+	Method   string   `json:"method,omitempty" structs:"method"`
+	Rules    []string `json:"rules" structs:"rules"`
+}
+
+// summarize groups the capabilities' techniques by tactic and behaviors by objective
+func summarize(capabilities []Capability) Summary {
+
+	tactics := map[string]map[string]*TechniqueSummary{}
+	objectives := map[string]map[string]*BehaviorSummary{}
+
+	for _, capability := range capabilities {
+		for _, t := range capability.Attack {
+			if tactics[t.Tactic] == nil {
+				tactics[t.Tactic] = map[string]*TechniqueSummary{}
+			}
+			technique := tactics[t.Tactic][t.ID]
+			if technique == nil {
+				technique = &TechniqueSummary{ID: t.ID, Technique: t.Technique, Subtechnique: t.Subtechnique}
+				tactics[t.Tactic][t.ID] = technique
+			}
+			technique.Rules = append(technique.Rules, capability.Rule)
+		}
+
+		for _, b := range capability.MBC {
+			if objectives[b.Objective] == nil {
+				objectives[b.Objective] = map[string]*BehaviorSummary{}
+			}
+			behavior := objectives[b.Objective][b.ID]
+			if behavior == nil {
+				behavior = &BehaviorSummary{ID: b.ID, Behavior: b.Behavior, Method: b.Method}
+				objectives[b.Objective][b.ID] = behavior
+			}
+			behavior.Rules = append(behavior.Rules, capability.Rule)
+		}
+	}
+
+	summary := Summary{}
+
+	for tactic, techniques := range tactics {
+		tacticSummary := TacticSummary{Tactic: tactic}
+		for _, technique := range techniques {
+			tacticSummary.Techniques = append(tacticSummary.Techniques, *technique)
+		}
+		sort.Slice(tacticSummary.Techniques, func(i, j int) bool { return tacticSummary.Techniques[i].ID < tacticSummary.Techniques[j].ID })
+		summary.Attack = append(summary.Attack, tacticSummary)
+	}
+	sort.Slice(summary.Attack, func(i, j int) bool { return summary.Attack[i].Tactic < summary.Attack[j].Tactic })
+
+	for objective, behaviors := range objectives {
+		objectiveSummary := ObjectiveSummary{Objective: objective}
+		for _, behavior := range behaviors {
+			objectiveSummary.Behaviors = append(objectiveSummary.Behaviors, *behavior)
+		}
+		sort.Slice(objectiveSummary.Behaviors, func(i, j int) bool { return objectiveSummary.Behaviors[i].ID < objectiveSummary.Behaviors[j].ID })
+		summary.MBC = append(summary.MBC, objectiveSummary)
+	}
+	sort.Slice(summary.MBC, func(i, j int) bool { return summary.MBC[i].Objective < summary.MBC[j].Objective })
+
+	return summary
+}
+
+// navigatorLayer is an ATT&CK Navigator layer (format 4.x)
+type navigatorLayer struct {
+	Name        string                 `json:"name"`
+	Versions    map[string]string      `json:"versions"`
+	Domain      string                 `json:"domain"`
+	Description string                 `json:"description"`
+	Techniques  []navigatorTechnique   `json:"techniques"`
+	Gradient    navigatorGradient      `json:"gradient"`
+	Metadata    []map[string]string    `json:"metadata"`
+	Sorting     int                    `json:"sorting"`
+	Layout      map[string]interface{} `json:"layout"`
+}
+
+type navigatorTechnique struct {
+	TechniqueID       string `json:"techniqueID"`
+	Tactic            string `json:"tactic"`
+	Score             int    `json:"score"`
+	Comment           string `json:"comment"`
+	Enabled           bool   `json:"enabled"`
+	ShowSubtechniques bool   `json:"showSubtechniques"`
+}
+
+type navigatorGradient struct {
+	Colors   []string `json:"colors"`
+	MinValue int      `json:"minValue"`
+	MaxValue int      `json:"maxValue"`
+}
+
+// writeNavigatorLayer writes the ATT&CK summary as a Navigator layer, scoring each
+// technique by the number of rules that map to it
+func writeNavigatorLayer(file string, results ResultsData) error {
+
+	layer := navigatorLayer{
+		Name:        "capa " + results.File.SHA256,
+		Versions:    map[string]string{"attack": "8", "navigator": "4.3", "layer": "4.1"},
+		Domain:      "enterprise-attack",
+		Description: "ATT&CK techniques identified by capa",
+		Gradient:    navigatorGradient{Colors: []string{"#ffffff", "#ff6666"}, MinValue: 0, MaxValue: 1},
+		Metadata:    []map[string]string{{"name": "sha256", "value": results.File.SHA256}},
+		Layout:      map[string]interface{}{"layout": "side"},
+	}
+
+	for _, tactic := range results.Summary.Attack {
+		for _, technique := range tactic.Techniques {
+			score := len(technique.Rules)
+			if score > layer.Gradient.MaxValue {
+				layer.Gradient.MaxValue = score
+			}
+			layer.Techniques = append(layer.Techniques, navigatorTechnique{
+				TechniqueID:       technique.ID,
+				Tactic:            navigatorTactic(tactic.Tactic),
+				Score:             score,
+				Comment:           strings.Join(technique.Rules, "\n"),
+				Enabled:           true,
+				ShowSubtechniques: strings.Contains(technique.ID, "."),
+			})
+		}
+	}
+
+	layerJSON, err := json.MarshalIndent(layer, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, layerJSON, 0644)
+}
+
+// navigatorTactic converts a tactic name such as "Defense Evasion" to its
+// Navigator short name "defense-evasion"
+func navigatorTactic(tactic string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(tactic)), " ", "-")
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// Error codes reported in ResultsData.ErrorCode
+const (
+	errUnsupportedFormat = "unsupported_format"
+	errCorruptedFile     = "corrupted_file"
+	errInvalidRules      = "invalid_rules"
+	errTimeout           = "timeout"
+	errEngineCrash       = "engine_crash"
+	errJSONDecode        = "json_decode"
+)
+
+// capa exits with these (negative) codes for the failures it recognises
+var exitCodes = map[int]string{
+	-10: errInvalidRules,      // E_MISSING_RULES
+	-12: errInvalidRules,      // E_INVALID_RULE
+	-13: errCorruptedFile,     // E_CORRUPT_FILE
+	-14: errUnsupportedFormat, // E_FILE_LIMITATION
+	-15: errInvalidRules,      // E_INVALID_SIG
+	-16: errUnsupportedFormat, // E_INVALID_FILE_TYPE
+	-17: errUnsupportedFormat, // E_INVALID_FILE_ARCH
+	-18: errUnsupportedFormat, // E_INVALID_FILE_OS
+}
+
+// classifyFailure maps a failed capa run to an error code and the reason capa gave for exiting
+func classifyFailure(ctx context.Context, err error, stderr string) (code string, reason string) {
+
+	reason = lastLine(stderr)
+	if len(reason) == 0 {
+		reason = err.Error()
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTimeout, reason
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		// exit codes are reported modulo 256, so -13 arrives as 243
+		status := exitErr.ExitCode()
+		if status > 127 {
+			status -= 256
+		}
+		if code, ok := exitCodes[status]; ok {
+			return code, reason
+		}
+	}
+
+	lower := strings.ToLower(stderr)
+	switch {
+	case strings.Contains(lower, "does not appear to be"),
+		strings.Contains(lower, "unsupported file"),
+		strings.Contains(lower, "supported file formats"):
+		return errUnsupportedFormat, reason
+	case strings.Contains(lower, "corrupt"),
+		strings.Contains(lower, "peformaterror"),
+		strings.Contains(lower, "invalid pe"):
+		return errCorruptedFile, reason
+	case strings.Contains(lower, "invalid rule"),
+		strings.Contains(lower, "failed to load rules"):
+		return errInvalidRules, reason
+	}
+
+	return errEngineCrash, reason
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -6,4 +6,5 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.7.0
 	github.com/urfave/cli v1.22.5
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -1,12 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"capa/utils"
@@ -28,46 +31,136 @@ var (
 
 // ResultsData - holds scan results
 type ResultsData struct {
-	Rules []string `json:"rules,omitempty" structs:"rules"`
-	Error string   `json:"error" structs:"error"`
+	File         FileInfo     `json:"file" structs:"file"`
+	Capabilities []Capability `json:"capabilities,omitempty" structs:"capabilities"`
+	Summary      Summary      `json:"summary" structs:"summary"`
+	RuleSet      RuleSet      `json:"rule_set" structs:"rule_set"`
+	Error        string       `json:"error" structs:"error"`
+	ErrorCode    string       `json:"error_code,omitempty" structs:"error_code"`
+	ExitReason   string       `json:"exit_reason,omitempty" structs:"exit_reason"`
 }
 
-// floss - holds full set of results
-type floss struct {
+// FileInfo - holds the file metadata capa reports
+type FileInfo struct {
+	Format      string `json:"format" structs:"format"`
+	Arch        string `json:"arch" structs:"arch"`
+	OS          string `json:"os" structs:"os"`
+	MD5         string `json:"md5" structs:"md5"`
+	SHA1        string `json:"sha1" structs:"sha1"`
+	SHA256      string `json:"sha256" structs:"sha256"`
+	CapaVersion string `json:"capa_version" structs:"capa_version"`
+}
+
+// Capability - holds a single matched capa rule
+type Capability struct {
+	Rule        string      `json:"rule" structs:"rule"`
+	Namespace   string      `json:"namespace" structs:"namespace"`
+	Scope       string      `json:"scope" structs:"scope"`
+	Attack      []Technique `json:"attack,omitempty" structs:"attack"`
+	MBC         []Behavior  `json:"mbc,omitempty" structs:"mbc"`
+	Matches     int         `json:"matches" structs:"matches"`
+	Functions   []string    `json:"functions,omitempty" structs:"functions"`
+	BasicBlocks []string    `json:"basic_blocks,omitempty" structs:"basic_blocks"`
+}
+
+// Technique - holds an ATT&CK mapping of a capability
+type Technique struct {
+	Tactic       string `json:"tactic" structs:"tactic"`
+	Technique    string `json:"technique" structs:"technique"`
+	Subtechnique string `json:"subtechnique,omitempty" structs:"subtechnique"`
+	ID           string `json:"id" structs:"id"`
+}
+
+// Behavior - holds an MBC mapping of a capability
+type Behavior struct {
+	Objective string `json:"objective" structs:"objective"`
+	Behavior  string `json:"behavior" structs:"behavior"`
+	Method    string `json:"method,omitempty" structs:"method"`
+	ID        string `json:"id" structs:"id"`
+}
+
+// capa - holds full set of results
+type capa struct {
 	Results ResultsData `json:"analysis" structs:"analysis"`
 }
 
-// scanFile scans file with all floss rules in the rules folder
-func scanFile(ctx context.Context, all bool) floss {
+// scanFile scans file with all capa rules in the rule set
+func scanFile(ctx context.Context, all bool, rules RuleSet) capa {
 
-	flossResults := floss{}
+	capaResults := capa{}
 
-	output, err := exec.CommandContext(ctx, "/opt/capa", "-q", "-j", path).Output()
-	if ctx.Err() == context.DeadlineExceeded {
-		return floss{ResultsData{Error: "timeout"}}
+	if _, err := rules.load(); err != nil {
+		return capa{ResultsData{RuleSet: rules, Error: err.Error(), ErrorCode: errInvalidRules}}
 	}
-	if err != nil {
-		return floss{ResultsData{Error: "cmd failed: /opt/capa" + path}}
+
+	args := append([]string{"-q", "-j"}, rules.args()...)
+	args = append(args, path)
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "/opt/capa", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil || ctx.Err() == context.DeadlineExceeded {
+		if err == nil {
+			err = ctx.Err()
+		}
+		log.Debug(stderr.String())
+
+		code, reason := classifyFailure(ctx, err, stderr.String())
+		return capa{ResultsData{
+			RuleSet:    rules,
+			Error:      fmt.Sprintf("cmd failed: /opt/capa %s: %s", strings.Join(args, " "), err),
+			ErrorCode:  code,
+			ExitReason: reason,
+		}}
 	}
 
-	flossResults.Results = parseFlossOutput(output, all)
+	capaResults.Results = parseCapaOutput(output, all)
+	capaResults.Results.RuleSet = rules
 
-	return flossResults
+	return capaResults
 }
 
-func parseFlossOutput(flossOutput []byte, all bool) (results ResultsData) {
+func parseCapaOutput(capaOutput []byte, all bool) (results ResultsData) {
+
+	results.Error = "nil"
 
-	var rules map[string]map[string]map[string]map[string]string
+	var report capaReport
 
-	err := json.Unmarshal(flossOutput, &rules)
+	err := json.Unmarshal(capaOutput, &report)
 	if err != nil {
-		log.Debug(errors.Wrap(err, "Error while unmarshaling summary manalyze output"))
+		log.Debug(errors.Wrap(err, "Error while unmarshaling capa output"))
+		results.Error = err.Error()
+		results.ErrorCode = errJSONDecode
+		return results
 	}
 
-	for key, _ := range rules["rules"] {
-		results.Rules = append(results.Rules, fmt.Sprintf("%s - %s", key, rules["rules"][key]["meta"]["namespace"]))
+	results.File = FileInfo{
+		Format:      report.Meta.Analysis.Format,
+		Arch:        report.Meta.Analysis.Arch,
+		OS:          report.Meta.Analysis.OS,
+		MD5:         report.Meta.Sample.MD5,
+		SHA1:        report.Meta.Sample.SHA1,
+		SHA256:      report.Meta.Sample.SHA256,
+		CapaVersion: report.Meta.Version,
 	}
 
+	for name, rule := range report.Rules {
+		results.Capabilities = append(results.Capabilities, newCapability(name, rule))
+	}
+
+	sort.Slice(results.Capabilities, func(i, j int) bool {
+		a, b := results.Capabilities[i], results.Capabilities[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Rule < b.Rule
+	})
+
+	results.Summary = summarize(results.Capabilities)
+
 	return results
 }
 
@@ -77,7 +170,7 @@ func main() {
 	app := cli.NewApp()
 
 	app.Name = "capa"
-	app.Usage = "Malscan floss plugin"
+	app.Usage = "Malscan capa plugin"
 	app.Version = "1.0.0"
 	app.Flags = []cli.Flag{
 		cli.BoolFlag{
@@ -90,6 +183,44 @@ func main() {
 			Usage:  "malcan plugin timeout (in seconds)",
 			EnvVar: "MALSCAN_TIMEOUT",
 		},
+		cli.StringFlag{
+			Name:   "navigator",
+			Usage:  "write an ATT&CK Navigator layer to this file",
+			EnvVar: "MALSCAN_CAPA_NAVIGATOR",
+		},
+		cli.StringSliceFlag{
+			Name:   "rules, r",
+			Usage:  "capa rule directory, may be repeated (default: embedded rules)",
+			EnvVar: "MALSCAN_CAPA_RULES",
+		},
+		cli.StringFlag{
+			Name:   "signatures, s",
+			Usage:  "FLIRT signature file or directory (default: embedded signatures)",
+			EnvVar: "MALSCAN_CAPA_SIGNATURES",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:  "rules",
+			Usage: "List the rules loaded from the rule directories or the embedded rules",
+			Action: func(c *cli.Context) error {
+				rules := newRuleSet(c.GlobalStringSlice("rules"), c.GlobalString("signatures"))
+
+				loaded, err := rules.load()
+				if err != nil {
+					return err
+				}
+
+				rulesJSON, _ := json.Marshal(struct {
+					RuleSet RuleSet `json:"rule_set"`
+					Rules   []Rule  `json:"rules"`
+				}{rules, loaded})
+
+				fmt.Println(string(rulesJSON))
+
+				return nil
+			},
+		},
 	}
 	app.Action = func(c *cli.Context) error {
 
@@ -103,13 +234,21 @@ func main() {
 		if c.Args().Present() {
 			path, _ = filepath.Abs(c.Args().First())
 
-			floss := scanFile(ctx, false)
+			rules := newRuleSet(c.StringSlice("rules"), c.String("signatures"))
+
+			capa := scanFile(ctx, false, rules)
+
+			if layer := c.String("navigator"); len(layer) != 0 && capa.Results.Error == "nil" {
+				if err := writeNavigatorLayer(layer, capa.Results); err != nil {
+					log.Debug(errors.Wrap(err, "Error while writing navigator layer"))
+				}
+			}
 
 			//Convert clamav results to json
-			flossJSON, _ := json.Marshal(floss)
+			capaJSON, _ := json.Marshal(capa)
 
 			//Print json results
-			fmt.Println(string(flossJSON))
+			fmt.Println(string(capaJSON))
 
 		}
 		return nil
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// capaReport is the part of capa's json output (-j) the plugin reads
+type capaReport struct {
+	Meta struct {
+		Version string `json:"version"`
+		Sample  struct {
+			MD5    string `json:"md5"`
+			SHA1   string `json:"sha1"`
+			SHA256 string `json:"sha256"`
+		} `json:"sample"`
+		Analysis struct {
+			Format string `json:"format"`
+			Arch   string `json:"arch"`
+			OS     string `json:"os"`
+		} `json:"analysis"`
+	} `json:"meta"`
+	Rules map[string]capaRule `json:"rules"`
+}
+
+type capaRule struct {
+	Meta struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		Scope     string `json:"scope"`
+		// capa 1.x writes the ATT&CK mappings under att&ck, later versions
+		// under attack
+		Attack       []capaEntry `json:"attack"`
+		LegacyAttack []capaEntry `json:"att&ck"`
+		MBC          []capaEntry `json:"mbc"`
+	} `json:"meta"`
+	Matches capaMatches `json:"matches"`
+}
+
+// capaEntry is an ATT&CK or MBC mapping, capa 1.x writes these as strings like
+// "Tactic::Technique::Subtechnique [T1234.001]", later versions as objects
+type capaEntry struct {
+	Parts []string `json:"parts"`
+	ID    string   `json:"id"`
+}
+
+var entryID = regexp.MustCompile(`^(.*?)\s*\[([^\]]+)\]$`)
+
+func (e *capaEntry) UnmarshalJSON(data []byte) error {
+
+	var entry string
+	if err := json.Unmarshal(data, &entry); err != nil {
+		type object capaEntry
+		return json.Unmarshal(data, (*object)(e))
+	}
+
+	if match := entryID.FindStringSubmatch(entry); match != nil {
+		entry = match[1]
+		e.ID = match[2]
+	}
+	e.Parts = strings.Split(entry, "::")
+
+	return nil
+}
+
+func (e capaEntry) part(i int) string {
+	if i < len(e.Parts) {
+		return strings.TrimSpace(e.Parts[i])
+	}
+	return ""
+}
+
+// capaMatches holds the addresses a rule matched at, capa 1.x keys an object by
+// address while later versions write a list of [address, match] pairs
+type capaMatches []uint64
+
+func (m *capaMatches) UnmarshalJSON(data []byte) error {
+
+	var byAddress map[string]json.RawMessage
+	if err := json.Unmarshal(data, &byAddress); err == nil {
+		for address := range byAddress {
+			if value, err := strconv.ParseUint(address, 0, 64); err == nil {
+				*m = append(*m, value)
+			}
+		}
+		return nil
+	}
+
+	var pairs [][]json.RawMessage
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if len(pair) == 0 {
+			continue
+		}
+		var address struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(pair[0], &address); err != nil {
+			continue
+		}
+		var value uint64
+		if err := json.Unmarshal(address.Value, &value); err == nil {
+			*m = append(*m, value)
+		}
+	}
+
+	return nil
+}
+
+// newCapability converts a capa rule match into a Capability
+func newCapability(name string, rule capaRule) Capability {
+
+	capability := Capability{
+		Rule:      name,
+		Namespace: rule.Meta.Namespace,
+		Scope:     rule.Meta.Scope,
+		Matches:   len(rule.Matches),
+	}
+
+	for _, entry := range append(rule.Meta.Attack, rule.Meta.LegacyAttack...) {
+		capability.Attack = append(capability.Attack, Technique{
+			Tactic:       entry.part(0),
+			Technique:    entry.part(1),
+			Subtechnique: entry.part(2),
+			ID:           entry.ID,
+		})
+	}
+
+	for _, entry := range rule.Meta.MBC {
+		capability.MBC = append(capability.MBC, Behavior{
+			Objective: entry.part(0),
+			Behavior:  entry.part(1),
+			Method:    entry.part(2),
+			ID:        entry.ID,
+		})
+	}
+
+	addresses := []uint64(rule.Matches)
+	sort.Slice(addresses, func(i, j int) bool { return addresses[i] < addresses[j] })
+
+	for _, address := range addresses {
+		switch rule.Meta.Scope {
+		case "function":
+			capability.Functions = append(capability.Functions, fmt.Sprintf("0x%x", address))
+		case "basic block":
+			capability.BasicBlocks = append(capability.BasicBlocks, fmt.Sprintf("0x%x", address))
+		}
+	}
+
+	return capability
+}
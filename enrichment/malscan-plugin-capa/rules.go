@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// embeddedRules is the capa-rules release matching the capa version in the image,
+// capa runs with it when no rule directories are given
+const embeddedRules = "/opt/capa-rules"
+
+// RuleSet - holds the rule directories and signature path capa runs with
+type RuleSet struct {
+	Embedded   bool     `json:"embedded" structs:"embedded"`
+	Rules      []string `json:"rules,omitempty" structs:"rules"`
+	Signatures string   `json:"signatures,omitempty" structs:"signatures"`
+	Count      int      `json:"count" structs:"count"`
+	Hash       string   `json:"hash,omitempty" structs:"hash"`
+}
+
+// Rule - holds a rule loaded from a rule directory
+type Rule struct {
+	Name      string `json:"name" structs:"name"`
+	Namespace string `json:"namespace" structs:"namespace"`
+	Source    string `json:"source" structs:"source"`
+	File      string `json:"file" structs:"file"`
+}
+
+// capaRuleFile is the part of a capa rule file the plugin reads
+type capaRuleFile struct {
+	Rule struct {
+		Meta struct {
+			Name      string `yaml:"name"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"meta"`
+	} `yaml:"rule"`
+}
+
+// newRuleSet describes the given directories, the embedded rules are used when none are given
+func newRuleSet(rules []string, signatures string) RuleSet {
+	return RuleSet{
+		Embedded:   len(rules) == 0,
+		Rules:      rules,
+		Signatures: signatures,
+	}
+}
+
+// dirs returns the rule directories capa loads
+func (r RuleSet) dirs() []string {
+	if r.Embedded {
+		return []string{embeddedRules}
+	}
+	return r.Rules
+}
+
+// args converts the rule set into capa command line arguments, capa takes a single
+// signature path and uses its embedded signatures without one
+func (r RuleSet) args() (args []string) {
+	for _, dir := range r.dirs() {
+		args = append(args, "-r", dir)
+	}
+	if len(r.Signatures) != 0 {
+		args = append(args, "-s", r.Signatures)
+	}
+	return args
+}
+
+// load reads every rule file and hashes the rule and signature files, so results
+// can be traced back to the exact rule set that produced them
+func (r *RuleSet) load() ([]Rule, error) {
+
+	var loaded []Rule
+
+	files, err := listFiles(r.dirs(), ".yml", ".yaml")
+	if err != nil {
+		return nil, err
+	}
+	var signatures []string
+	if len(r.Signatures) != 0 {
+		signatures, err = listFiles([]string{r.Signatures}, ".sig", ".pat")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	hash := sha256.New()
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(data)
+
+		var rule capaRuleFile
+		if err := yaml.Unmarshal(data, &rule); err != nil {
+			log.Debug(errors.Wrapf(err, "Error while parsing rule %s", file))
+			continue
+		}
+		if len(rule.Rule.Meta.Name) == 0 {
+			continue
+		}
+
+		loaded = append(loaded, Rule{
+			Name:      rule.Rule.Meta.Name,
+			Namespace: rule.Rule.Meta.Namespace,
+			Source:    r.source(file),
+			File:      file,
+		})
+	}
+
+	for _, file := range signatures {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		hash.Write(data)
+	}
+
+	r.Count = len(loaded)
+	if len(files) != 0 || len(signatures) != 0 {
+		r.Hash = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		if loaded[i].Namespace != loaded[j].Namespace {
+			return loaded[i].Namespace < loaded[j].Namespace
+		}
+		return loaded[i].Name < loaded[j].Name
+	})
+
+	return loaded, nil
+}
+
+// source names the rule directory a rule file was loaded from
+func (r RuleSet) source(file string) string {
+	if r.Embedded {
+		return "embedded"
+	}
+	for _, dir := range r.Rules {
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			return dir
+		}
+	}
+	return ""
+}
+
+// listFiles walks dirs, skipping hidden directories, and returns the sorted
+// files with one of the extensions
+func listFiles(dirs []string, extensions ...string) ([]string, error) {
+
+	var files []string
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if file != dir && strings.HasPrefix(info.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			for _, ext := range extensions {
+				if strings.HasSuffix(strings.ToLower(file), ext) {
+					files = append(files, file)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error while reading %s", dir)
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	goBuildInfoMagic = []byte("\xff Go buildinf:")
+	rustCommit       = regexp.MustCompile(`/rustc/([0-9a-f]{40})/`)
+	rustVersion      = regexp.MustCompile(`rustc version ([^\x00]+)`)
+	rustCrate        = regexp.MustCompile(`\.cargo[/\\]registry[/\\]src[/\\][^/\\]+[/\\]([A-Za-z0-9_-]+-\d+\.\d+\.\d+[A-Za-z0-9.+-]*)[/\\]`)
+	rustMarkers      = [][]byte{[]byte("rust_panic"), []byte("rust_begin_unwind"), []byte("RUST_BACKTRACE")}
+)
+
+// GoBuildInfo json object
+type GoBuildInfo struct {
+	Version  string            `json:"version" structs:"version"`
+	Path     string            `json:"path,omitempty" structs:"path"`
+	Main     *Module           `json:"main,omitempty" structs:"main"`
+	Deps     []Module          `json:"deps,omitempty" structs:"deps"`
+	Settings map[string]string `json:"settings,omitempty" structs:"settings"`
+	BuildID  string            `json:"build_id,omitempty" structs:"build_id"`
+}
+
+// Module json object
+type Module struct {
+	Path    string `json:"path" structs:"path"`
+	Version string `json:"version" structs:"version"`
+	Sum     string `json:"sum,omitempty" structs:"sum"`
+}
+
+// RustBuildInfo json object
+type RustBuildInfo struct {
+	CompilerVersion string   `json:"compiler_version,omitempty" structs:"compiler_version"`
+	Commit          string   `json:"commit,omitempty" structs:"commit"`
+	Crates          []string `json:"crates,omitempty" structs:"crates"`
+}
+
+// goBuildInfo reads the build information the go linker embeds, it returns nil
+// for binaries not built by go
+func goBuildInfo(f *elf.File, data []byte) *GoBuildInfo {
+
+	info := &GoBuildInfo{BuildID: goBuildID(f)}
+
+	start := -1
+	if section := f.Section(".go.buildinfo"); section != nil && section.Type != elf.SHT_NOBITS {
+		start = int(section.Offset)
+	}
+	if start < 0 || start+32 > len(data) || !bytes.HasPrefix(data[start:], goBuildInfoMagic) {
+		start = bytes.Index(data, goBuildInfoMagic)
+	}
+	if start < 0 || start+32 > len(data) {
+		if len(info.BuildID) != 0 {
+			return info
+		}
+		return nil
+	}
+
+	header := data[start:]
+	ptrSize := int(header[14])
+	flags := header[15]
+
+	var version, modinfo string
+
+	if flags&2 != 0 {
+		// go 1.18 and later write the strings inline after the header
+		rest := header[32:]
+		version, rest = varintString(rest)
+		modinfo, _ = varintString(rest)
+	} else if ptrSize == 4 || ptrSize == 8 {
+		// earlier versions point to the runtime's string headers
+		var order binary.ByteOrder = binary.LittleEndian
+		if flags&1 != 0 {
+			order = binary.BigEndian
+		}
+		version = readGoString(f, data, order, ptrSize, readPointer(order, ptrSize, header[16:]))
+		modinfo = readGoString(f, data, order, ptrSize, readPointer(order, ptrSize, header[16+ptrSize:]))
+	}
+
+	info.Version = version
+
+	// modinfo is wrapped in 16 byte sentinels
+	if len(modinfo) >= 33 && modinfo[len(modinfo)-17] == '\n' {
+		modinfo = modinfo[16 : len(modinfo)-16]
+	}
+	parseModInfo(info, modinfo)
+
+	if len(info.Version) == 0 && len(info.BuildID) == 0 {
+		return nil
+	}
+
+	return info
+}
+
+// parseModInfo reads the path, mod, dep and build lines of go's module information
+func parseModInfo(info *GoBuildInfo, modinfo string) {
+
+	for _, line := range strings.Split(modinfo, "\n") {
+		fields := strings.Split(line, "\t")
+		switch fields[0] {
+		case "path":
+			if len(fields) > 1 {
+				info.Path = fields[1]
+			}
+		case "mod":
+			if len(fields) > 2 {
+				info.Main = &Module{Path: fields[1], Version: fields[2]}
+				if len(fields) > 3 {
+					info.Main.Sum = fields[3]
+				}
+			}
+		case "dep":
+			if len(fields) > 2 {
+				dep := Module{Path: fields[1], Version: fields[2]}
+				if len(fields) > 3 {
+					dep.Sum = fields[3]
+				}
+				info.Deps = append(info.Deps, dep)
+			}
+		case "=>":
+			// a replacement applies to the dependency before it
+			if len(fields) > 2 && len(info.Deps) != 0 {
+				info.Deps[len(info.Deps)-1].Path += " => " + fields[1]
+				info.Deps[len(info.Deps)-1].Version = fields[2]
+			}
+		case "build":
+			if len(fields) > 1 {
+				if i := strings.Index(fields[1], "="); i > 0 {
+					if info.Settings == nil {
+						info.Settings = map[string]string{}
+					}
+					info.Settings[fields[1][:i]] = fields[1][i+1:]
+				}
+			}
+		}
+	}
+}
+
+// goBuildID reads the build id note the go linker adds
+func goBuildID(f *elf.File) string {
+
+	section := f.Section(".note.go.buildid")
+	if section == nil {
+		return ""
+	}
+	note, err := section.Data()
+	if err != nil || len(note) < 16 {
+		return ""
+	}
+
+	nameSize := int(f.ByteOrder.Uint32(note[0:]))
+	descSize := int(f.ByteOrder.Uint32(note[4:]))
+	desc := 12 + (nameSize+3)&^3
+	if desc+descSize > len(note) || descSize < 0 {
+		return ""
+	}
+
+	return string(note[desc : desc+descSize])
+}
+
+func varintString(data []byte) (string, []byte) {
+	length, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < length {
+		return "", nil
+	}
+	return string(data[n : n+int(length)]), data[n+int(length):]
+}
+
+func readPointer(order binary.ByteOrder, ptrSize int, data []byte) uint64 {
+	if len(data) < ptrSize {
+		return 0
+	}
+	if ptrSize == 4 {
+		return uint64(order.Uint32(data))
+	}
+	return order.Uint64(data)
+}
+
+// readGoString reads the go string header at addr and the bytes it points to
+func readGoString(f *elf.File, data []byte, order binary.ByteOrder, ptrSize int, addr uint64) string {
+
+	header := readVirtual(f, data, addr, 2*ptrSize)
+	if len(header) < 2*ptrSize {
+		return ""
+	}
+	pointer := readPointer(order, ptrSize, header)
+	length := readPointer(order, ptrSize, header[ptrSize:])
+	if length > 1<<20 {
+		return ""
+	}
+
+	return string(readVirtual(f, data, pointer, int(length)))
+}
+
+// readVirtual returns size bytes at a virtual address using the loadable segments
+func readVirtual(f *elf.File, data []byte, addr uint64, size int) []byte {
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_LOAD && addr >= prog.Vaddr && addr-prog.Vaddr+uint64(size) <= prog.Filesz {
+			return slice(data, prog.Off+addr-prog.Vaddr, uint64(size))
+		}
+	}
+	return nil
+}
+
+// rustBuildInfo reads the compiler version, compiler commit and crates rustc leaves
+// in a binary, it returns nil for binaries not built by rustc
+func rustBuildInfo(f *elf.File, data []byte) *RustBuildInfo {
+
+	info := &RustBuildInfo{}
+
+	if comment := f.Section(".comment"); comment != nil {
+		if raw, err := comment.Data(); err == nil {
+			if match := rustVersion.FindSubmatch(raw); match != nil {
+				info.CompilerVersion = string(match[1])
+			}
+		}
+	}
+
+	// panic locations embed the paths of the standard library and crate sources
+	if match := rustCommit.FindSubmatch(data); match != nil {
+		info.Commit = string(match[1])
+	}
+
+	crates := map[string]bool{}
+	for _, match := range rustCrate.FindAllSubmatch(data, -1) {
+		crates[string(match[1])] = true
+	}
+	for crate := range crates {
+		info.Crates = append(info.Crates, crate)
+	}
+	sort.Strings(info.Crates)
+
+	if len(info.CompilerVersion) != 0 || len(info.Commit) != 0 || len(info.Crates) != 0 {
+		return info
+	}
+
+	for _, marker := range rustMarkers {
+		if bytes.Contains(data, marker) {
+			return info
+		}
+	}
+
+	return nil
+}
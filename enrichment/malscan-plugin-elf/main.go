@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-elf/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "elf"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// ELF json object (this is what gets output)
+type ELF struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Class        string
+	Endianness   string
+	OSABI        string
+	Type         string
+	Architecture string
+	EntryPoint   string
+	Interpreter  string
+	Libraries    []string
+	RPath        string
+	RunPath      string
+	Imports      []Symbol
+	Exports      []Symbol
+	Sections     []Section
+	Segments     []Segment
+	Stripped     bool
+	Static       bool
+	Compilers    []string
+	Packer       *Packer
+	Go           *GoBuildInfo
+	Rust         *RustBuildInfo
+	Error        string `json:"error" structs:"error"`
+}
+
+// Symbol json object
+type Symbol struct {
+	Name    string `json:"name" structs:"name"`
+	Type    string `json:"type" structs:"type"`
+	Binding string `json:"binding" structs:"binding"`
+	Version string `json:"version,omitempty" structs:"version"`
+	Library string `json:"library,omitempty" structs:"library"`
+	Address string `json:"address,omitempty" structs:"address"`
+}
+
+// Section json object
+type Section struct {
+	Name    string   `json:"name" structs:"name"`
+	Type    string   `json:"type" structs:"type"`
+	Address string   `json:"address" structs:"address"`
+	Offset  string   `json:"offset" structs:"offset"`
+	Size    uint64   `json:"size" structs:"size"`
+	Flags   []string `json:"flags" structs:"flags"`
+	Entropy float64  `json:"entropy" structs:"entropy"`
+}
+
+// Segment json object
+type Segment struct {
+	Type       string  `json:"type" structs:"type"`
+	Flags      string  `json:"flags" structs:"flags"`
+	Offset     string  `json:"offset" structs:"offset"`
+	Address    string  `json:"address" structs:"address"`
+	FileSize   uint64  `json:"file_size" structs:"file_size"`
+	MemorySize uint64  `json:"memory_size" structs:"memory_size"`
+	Entropy    float64 `json:"entropy" structs:"entropy"`
+}
+
+// AvScan performs the elf analysis, giving up after timeout seconds
+func AvScan(timeout int) ELF {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		// debug/elf panics on some malformed files
+		defer func() {
+			if r := recover(); r != nil {
+				done <- ResultsData{Error: fmt.Sprint(r)}
+			}
+		}()
+		done <- analyzeELF(path)
+	}()
+
+	select {
+	case results := <-done:
+		return ELF{Results: results}
+	case <-ctx.Done():
+		return ELF{Results: ResultsData{Error: fmt.Sprintf("analysis of %s timed out", path)}}
+	}
+}
+
+// analyzeELF parses the file with debug/elf
+func analyzeELF(file string) ResultsData {
+
+	results := ResultsData{Error: "nil"}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing elf file"))
+		results.Error = err.Error()
+		return results
+	}
+	defer f.Close()
+
+	results.Class = f.Class.String()
+	results.Endianness = f.Data.String()
+	results.OSABI = f.OSABI.String()
+	results.Type = f.Type.String()
+	results.Architecture = f.Machine.String()
+	results.EntryPoint = hexNumber(f.Entry)
+
+	dynamic := false
+	for _, prog := range f.Progs {
+		switch prog.Type {
+		case elf.PT_INTERP:
+			interp, _ := ioutil.ReadAll(prog.Open())
+			results.Interpreter = strings.TrimRight(string(interp), "\x00")
+		case elf.PT_DYNAMIC:
+			dynamic = true
+		}
+
+		results.Segments = append(results.Segments, Segment{
+			Type:       prog.Type.String(),
+			Flags:      segmentFlags(prog.Flags),
+			Offset:     hexNumber(prog.Off),
+			Address:    hexNumber(prog.Vaddr),
+			FileSize:   prog.Filesz,
+			MemorySize: prog.Memsz,
+			Entropy:    entropy(slice(data, prog.Off, prog.Filesz)),
+		})
+	}
+
+	if dynamic {
+		results.Libraries, _ = f.ImportedLibraries()
+		if rpath, _ := f.DynString(elf.DT_RPATH); len(rpath) != 0 {
+			results.RPath = strings.Join(rpath, ":")
+		}
+		if runpath, _ := f.DynString(elf.DT_RUNPATH); len(runpath) != 0 {
+			results.RunPath = strings.Join(runpath, ":")
+		}
+		results.Imports, results.Exports = dynamicSymbols(f)
+	}
+
+	results.Static = len(results.Interpreter) == 0 && len(results.Libraries) == 0
+	results.Stripped = f.Section(".symtab") == nil
+
+	for _, section := range f.Sections {
+		if section.Type == elf.SHT_NULL {
+			continue
+		}
+		s := Section{
+			Name:    section.Name,
+			Type:    section.Type.String(),
+			Address: hexNumber(section.Addr),
+			Offset:  hexNumber(section.Offset),
+			Size:    section.Size,
+			Flags:   sectionFlags(section.Flags),
+		}
+		if section.Type != elf.SHT_NOBITS {
+			s.Entropy = entropy(slice(data, section.Offset, section.Size))
+		}
+		results.Sections = append(results.Sections, s)
+	}
+
+	if comment := f.Section(".comment"); comment != nil {
+		if raw, err := comment.Data(); err == nil {
+			for _, compiler := range strings.Split(string(raw), "\x00") {
+				if compiler = strings.TrimSpace(compiler); len(compiler) != 0 && !contains(results.Compilers, compiler) {
+					results.Compilers = append(results.Compilers, compiler)
+				}
+			}
+		}
+	}
+
+	results.Packer = detectPacker(f, data)
+	results.Go = goBuildInfo(f, data)
+	results.Rust = rustBuildInfo(f, data)
+
+	return results
+}
+
+// dynamicSymbols splits the dynamic symbol table into undefined (imported) and
+// defined global (exported) symbols
+func dynamicSymbols(f *elf.File) ([]Symbol, []Symbol) {
+
+	var imports, exports []Symbol
+
+	symbols, err := f.DynamicSymbols()
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading dynamic symbols"))
+		return nil, nil
+	}
+
+	// symbol versions and the libraries they bind to come from the version tables
+	versions := map[string]elf.ImportedSymbol{}
+	if imported, err := f.ImportedSymbols(); err == nil {
+		for _, symbol := range imported {
+			versions[symbol.Name] = symbol
+		}
+	}
+
+	for _, symbol := range symbols {
+		if len(symbol.Name) == 0 {
+			continue
+		}
+		bind := elf.ST_BIND(symbol.Info)
+		s := Symbol{
+			Name:    symbol.Name,
+			Type:    elf.ST_TYPE(symbol.Info).String(),
+			Binding: bind.String(),
+			Version: versions[symbol.Name].Version,
+			Library: versions[symbol.Name].Library,
+		}
+
+		if symbol.Section == elf.SHN_UNDEF {
+			imports = append(imports, s)
+		} else if bind == elf.STB_GLOBAL || bind == elf.STB_WEAK {
+			s.Address = hexNumber(symbol.Value)
+			exports = append(exports, s)
+		}
+	}
+
+	sort.Slice(imports, func(i, j int) bool { return imports[i].Name < imports[j].Name })
+	sort.Slice(exports, func(i, j int) bool { return exports[i].Name < exports[j].Name })
+
+	return imports, exports
+}
+
+func sectionFlags(flags elf.SectionFlag) []string {
+	if flags == 0 {
+		return []string{}
+	}
+	return strings.Split(flags.String(), "+")
+}
+
+func segmentFlags(flags elf.ProgFlag) string {
+	perms := []byte("---")
+	if flags&elf.PF_R != 0 {
+		perms[0] = 'r'
+	}
+	if flags&elf.PF_W != 0 {
+		perms[1] = 'w'
+	}
+	if flags&elf.PF_X != 0 {
+		perms[2] = 'x'
+	}
+	return string(perms)
+}
+
+// slice returns the part of data in [offset, offset+size) that lies inside the file
+func slice(data []byte, offset uint64, size uint64) []byte {
+	if offset >= uint64(len(data)) {
+		return nil
+	}
+	end := offset + size
+	if end > uint64(len(data)) || end < offset {
+		end = uint64(len(data))
+	}
+	return data[offset:end]
+}
+
+// entropy returns the shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]float64
+	for _, b := range data {
+		counts[b]++
+	}
+
+	total := float64(len(data))
+	result := 0.0
+	for _, count := range counts {
+		if count != 0 {
+			p := count / total
+			result -= p * math.Log2(p)
+		}
+	}
+
+	return math.Round(result*1000) / 1000
+}
+
+func hexNumber(n uint64) string {
+	return fmt.Sprintf("0x%x", n)
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "ELF"
+	app.Usage = "Malscan elf plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			elf := AvScan(c.Int("timeout"))
+
+			// convert to JSON
+			elfJSON, _ := json.Marshal(elf)
+
+			fmt.Println(string(elfJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
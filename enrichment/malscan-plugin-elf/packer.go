@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"regexp"
+)
+
+var (
+	upxMagic   = []byte("UPX!")
+	upxInfo    = []byte("This file is packed with the UPX executable packer")
+	upxVersion = regexp.MustCompile(`\$Id: UPX (\d+\.\d+[\w.]*)`)
+)
+
+// Packer json object
+type Packer struct {
+	Name       string   `json:"name" structs:"name"`
+	Version    string   `json:"version,omitempty" structs:"version"`
+	Indicators []string `json:"indicators" structs:"indicators"`
+}
+
+// detectPacker looks for the headers and strings UPX leaves in packed files and
+// for the shape of a packed file when they have been scrubbed, it returns nil
+// when nothing points to packing
+func detectPacker(f *elf.File, data []byte) *Packer {
+
+	packer := &Packer{Name: "UPX"}
+
+	// UPX puts its l_info header, which starts with a checksum then the magic,
+	// straight after the program headers
+	if header := programHeadersEnd(f, data); header >= 0 && header+8 <= len(data) && bytes.Equal(data[header+4:header+8], upxMagic) {
+		packer.Indicators = append(packer.Indicators, "UPX! l_info header after program headers")
+	}
+
+	// and a pack header near the end of the file
+	tail := data
+	if len(tail) > 0x1000 {
+		tail = tail[len(tail)-0x1000:]
+	}
+	if bytes.Contains(tail, upxMagic) {
+		packer.Indicators = append(packer.Indicators, "UPX! pack header at end of file")
+	}
+
+	if bytes.Contains(data, upxInfo) {
+		packer.Indicators = append(packer.Indicators, "UPX $Info string")
+	}
+
+	if match := upxVersion.FindSubmatch(data); match != nil {
+		packer.Version = string(match[1])
+		packer.Indicators = append(packer.Indicators, "UPX $Id string")
+	}
+
+	if len(packer.Indicators) != 0 {
+		if len(f.Sections) == 0 {
+			packer.Indicators = append(packer.Indicators, "no section headers")
+		}
+		return packer
+	}
+
+	// packers that strip their markers still leave a file with no section
+	// headers whose code is one high entropy segment
+	if len(f.Sections) != 0 {
+		return nil
+	}
+	for _, prog := range f.Progs {
+		if prog.Type == elf.PT_LOAD && prog.Flags&elf.PF_X != 0 && entropy(slice(data, prog.Off, prog.Filesz)) > 7.2 {
+			return &Packer{
+				Name:       "unknown",
+				Indicators: []string{"no section headers", "high entropy executable segment"},
+			}
+		}
+	}
+
+	return nil
+}
+
+// programHeadersEnd returns the file offset just past the program header table
+func programHeadersEnd(f *elf.File, data []byte) int {
+
+	if len(data) < 64 {
+		return -1
+	}
+
+	order := f.ByteOrder
+	if f.Class == elf.ELFCLASS64 {
+		phoff := order.Uint64(data[32:])
+		phentsize := order.Uint16(data[54:])
+		phnum := order.Uint16(data[56:])
+		return int(phoff) + int(phentsize)*int(phnum)
+	}
+
+	phoff := order.Uint32(data[28:])
+	phentsize := order.Uint16(data[42:])
+	phnum := order.Uint16(data[44:])
+	return int(phoff) + int(phentsize)*int(phnum)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+)
+
+// histogramBins split the 0 to 8 bits per byte range into half bit bins
+const histogramBins = 16
+
+// Windows json object, Entropy[i] is the entropy of the Size bytes at i*Step
+type Windows struct {
+	Size    int       `json:"size" structs:"size"`
+	Step    int       `json:"step" structs:"step"`
+	Entropy []float64 `json:"entropy" structs:"entropy"`
+}
+
+// HistogramBin json object, the number of windows whose entropy is in [Low, High)
+type HistogramBin struct {
+	Low   float64 `json:"low" structs:"low"`
+	High  float64 `json:"high" structs:"high"`
+	Count int     `json:"count" structs:"count"`
+}
+
+// entropy returns the shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	return countsEntropy(&counts, len(data))
+}
+
+func countsEntropy(counts *[256]int, total int) float64 {
+
+	if total == 0 {
+		return 0
+	}
+
+	result := 0.0
+	for _, count := range counts {
+		if count != 0 {
+			p := float64(count) / float64(total)
+			result -= p * math.Log2(p)
+		}
+	}
+
+	return math.Round(result*1000) / 1000
+}
+
+// slidingEntropy computes the entropy of window sized blocks, stepping by the
+// window size or further when that would give more than points values
+func slidingEntropy(data []byte, window int, points int) Windows {
+
+	if window > len(data) {
+		window = len(data)
+	}
+	if window <= 0 {
+		return Windows{Entropy: []float64{}}
+	}
+
+	span := len(data) - window
+	step := window
+	if points > 1 && span/step+1 > points {
+		step = (span + points - 2) / (points - 1)
+	}
+
+	windows := Windows{Size: window, Step: step, Entropy: []float64{}}
+
+	for offset := 0; offset <= span; offset += step {
+		windows.Entropy = append(windows.Entropy, entropy(data[offset:offset+window]))
+	}
+
+	return windows
+}
+
+// histogram counts the window entropies in half bit bins
+func histogram(windows Windows) []HistogramBin {
+
+	bins := make([]HistogramBin, histogramBins)
+	width := 8.0 / histogramBins
+	for i := range bins {
+		bins[i].Low = float64(i) * width
+		bins[i].High = float64(i+1) * width
+	}
+
+	for _, value := range windows.Entropy {
+		i := int(value / width)
+		if i >= histogramBins {
+			i = histogramBins - 1
+		}
+		bins[i].Count++
+	}
+
+	return bins
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-entropy/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "entropy"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// Entropy json object (this is what gets output)
+type Entropy struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Size       int
+	Entropy    float64
+	Windows    Windows
+	Histogram  []HistogramBin
+	Format     string
+	Sections   []Section
+	Overlay    *Overlay
+	Packed     bool
+	Confidence int
+	Packers    []string
+	Heuristics []Heuristic
+	Error      string `json:"error" structs:"error"`
+}
+
+// AvScan performs the entropy analysis, giving up after timeout seconds
+func AvScan(timeout int, window int, points int) Entropy {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		// debug/pe and debug/elf panic on some malformed files
+		defer func() {
+			if r := recover(); r != nil {
+				done <- ResultsData{Error: fmt.Sprint(r)}
+			}
+		}()
+		done <- analyzeEntropy(path, window, points)
+	}()
+
+	select {
+	case results := <-done:
+		return Entropy{Results: results}
+	case <-ctx.Done():
+		return Entropy{Results: ResultsData{Error: fmt.Sprintf("analysis of %s timed out", path)}}
+	}
+}
+
+// analyzeEntropy measures the file's entropy as a whole, in windows and per
+// section, and scores how likely it is to be packed
+func analyzeEntropy(file string, window int, points int) ResultsData {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return ResultsData{Error: err.Error()}
+	}
+
+	results := ResultsData{
+		Size:     len(data),
+		Entropy:  entropy(data),
+		Windows:  slidingEntropy(data, window, points),
+		Sections: []Section{},
+		Error:    "nil",
+	}
+	results.Histogram = histogram(results.Windows)
+
+	img := parseImage(data)
+	if img != nil {
+		results.Format = img.format
+		results.Sections = img.sections
+		results.Overlay = img.overlay
+	}
+
+	results.Packers, results.Heuristics, results.Confidence = packingHeuristics(data, results.Entropy, img)
+	results.Packed = results.Confidence >= packedConfidence
+
+	return results
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "Entropy"
+	app.Usage = "Malscan entropy plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+		cli.IntFlag{
+			Name:   "window",
+			Value:  1024,
+			Usage:  "sliding window size (in bytes)",
+			EnvVar: "MALSCAN_ENTROPY_WINDOW",
+		},
+		cli.IntFlag{
+			Name:   "points",
+			Value:  512,
+			Usage:  "most sliding window values to report, the window steps further on larger files",
+			EnvVar: "MALSCAN_ENTROPY_POINTS",
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			entropy := AvScan(c.Int("timeout"), c.Int("window"), c.Int("points"))
+
+			// convert to JSON
+			entropyJSON, _ := json.Marshal(entropy)
+
+			fmt.Println(string(entropyJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// entropy thresholds in bits per byte, compiled code sits around 6 and
+// compressed or encrypted data close to 8
+const (
+	highSectionEntropy = 7.0
+	highFileEntropy    = 7.2
+	highOverlayEntropy = 7.5
+	minOverlaySize     = 1024
+	packedConfidence   = 50
+)
+
+// Heuristic json object, one reason to think the file is packed and how much it adds
+// to the confidence
+type Heuristic struct {
+	Name        string `json:"name" structs:"name"`
+	Description string `json:"description" structs:"description"`
+	Score       int    `json:"score" structs:"score"`
+}
+
+// packerSections are section names packers leave behind
+var packerSections = map[string]string{
+	"UPX0":       "UPX",
+	"UPX1":       "UPX",
+	"UPX2":       "UPX",
+	"UPX!":       "UPX",
+	".UPX0":      "UPX",
+	".UPX1":      "UPX",
+	".aspack":    "ASPack",
+	".adata":     "ASPack",
+	".ASPack":    "ASPack",
+	".MPRESS1":   "MPRESS",
+	".MPRESS2":   "MPRESS",
+	".petite":    "Petite",
+	".nsp0":      "NsPack",
+	".nsp1":      "NsPack",
+	".nsp2":      "NsPack",
+	"pec1":       "PECompact",
+	"pec2":       "PECompact",
+	"PEC2":       "PECompact",
+	"PEC2TO":     "PECompact",
+	"PECompact2": "PECompact",
+	".themida":   "Themida",
+	".winlice":   "WinLicense",
+	".vmp0":      "VMProtect",
+	".vmp1":      "VMProtect",
+	".vmp2":      "VMProtect",
+	".enigma1":   "Enigma",
+	".enigma2":   "Enigma",
+	"kkrunchy":   "kkrunchy",
+	"MEW":        "MEW",
+	".RLPack":    "RLPack",
+	".yP":        "Y0da Crypter",
+	".y0da":      "Y0da Crypter",
+	".perplex":   "Perplex",
+	"ExeS":       "EXE Stealth",
+	".MaskPE":    "MaskPE",
+	".spack":     "Simple Pack",
+	".svkp":      "SVKP",
+	"BitArts":    "Crunch",
+}
+
+// packingHeuristics scores how likely the file is to be packed or encrypted from
+// 0 to 100, naming the packers it recognises
+func packingHeuristics(data []byte, fileEntropy float64, img *image) ([]string, []Heuristic, int) {
+
+	packers := []string{}
+	heuristics := []Heuristic{}
+	add := func(name string, score int, format string, args ...interface{}) {
+		heuristics = append(heuristics, Heuristic{Name: name, Description: fmt.Sprintf(format, args...), Score: score})
+	}
+
+	if fileEntropy > highFileEntropy {
+		add("high_file_entropy", 15, "file entropy %.3f", fileEntropy)
+	}
+
+	if img == nil {
+		return packers, heuristics, confidence(heuristics)
+	}
+
+	var named, highEntropy, writableExecutable, virtualOnly []string
+	for _, section := range img.sections {
+		if packer, ok := packerSections[section.Name]; ok {
+			named = append(named, section.Name)
+			if !contains(packers, packer) {
+				packers = append(packers, packer)
+			}
+		}
+		if section.Executable && section.Entropy > highSectionEntropy {
+			highEntropy = append(highEntropy, fmt.Sprintf("%s (%.3f)", section.Name, section.Entropy))
+		}
+		if section.Executable && section.Writable {
+			writableExecutable = append(writableExecutable, section.Name)
+		}
+		// space reserved for code unpacked at run time
+		if section.Executable && section.Size == 0 && section.VirtualSize != 0 {
+			virtualOnly = append(virtualOnly, section.Name)
+		}
+	}
+
+	// UPX marks the elf files it packs with its magic in the first and last pages
+	if img.format == "elf" && (bytes.Contains(head(data, 0x1000), []byte("UPX!")) || bytes.Contains(tail(data, 0x1000), []byte("UPX!"))) {
+		add("packer_magic", 50, "UPX! magic in the packed file headers")
+		if !contains(packers, "UPX") {
+			packers = append(packers, "UPX")
+		}
+	}
+
+	if len(named) != 0 {
+		add("packer_section", 50, "packer section names %s", strings.Join(named, ", "))
+	}
+	if len(highEntropy) != 0 {
+		add("high_entropy_executable_section", 25, "executable sections with high entropy %s", strings.Join(highEntropy, ", "))
+	}
+	if len(writableExecutable) != 0 {
+		add("writable_executable_section", 10, "writable and executable sections %s", strings.Join(writableExecutable, ", "))
+	}
+	if len(virtualOnly) != 0 {
+		add("virtual_only_section", 10, "executable sections without raw data %s", strings.Join(virtualOnly, ", "))
+	}
+
+	section := entrySection(img)
+	switch {
+	case len(img.sections) == 0 || img.segments:
+		add("no_sections", 15, "no section headers")
+	case !img.entryKnown:
+	case section < 0:
+		add("entry_point_outside_sections", 15, "entry point 0x%x is outside every section", img.entry)
+	case !img.sections[section].Executable:
+		add("entry_point_section", 10, "entry point is in non executable section %s", img.sections[section].Name)
+	case section == len(img.sections)-1 && section != firstExecutable(img):
+		add("entry_point_section", 10, "entry point is in the last section %s", img.sections[section].Name)
+	}
+
+	if img.overlay != nil && img.overlay.Size >= minOverlaySize && img.overlay.Entropy > highOverlayEntropy {
+		add("high_entropy_overlay", 10, "%d byte overlay with entropy %.3f", img.overlay.Size, img.overlay.Entropy)
+	}
+
+	return packers, heuristics, confidence(heuristics)
+}
+
+// entrySection returns the index of the section holding the entry point, or -1,
+// sections that are not loaded have no address
+func entrySection(img *image) int {
+	for i, section := range img.sections {
+		if section.address == 0 {
+			continue
+		}
+		size := section.VirtualSize
+		if section.Size > size {
+			size = section.Size
+		}
+		if img.entry >= section.address && img.entry < section.address+size {
+			return i
+		}
+	}
+	return -1
+}
+
+func firstExecutable(img *image) int {
+	for i, section := range img.sections {
+		if section.Executable {
+			return i
+		}
+	}
+	return -1
+}
+
+// confidence adds up the heuristic scores, capped at 100
+func confidence(heuristics []Heuristic) int {
+	score := 0
+	for _, heuristic := range heuristics {
+		score += heuristic.Score
+	}
+	if score > 100 {
+		return 100
+	}
+	return score
+}
+
+func head(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+	return data[:n]
+}
+
+func tail(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+	return data[len(data)-n:]
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/pe"
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// pe section characteristics
+const (
+	sectionCode       = 0x00000020
+	sectionExecute    = 0x20000000
+	sectionWrite      = 0x80000000
+	directorySecurity = 4
+)
+
+// Section json object, a pe or elf section, or a loadable segment for elf files
+// without section headers
+type Section struct {
+	Name           string  `json:"name" structs:"name"`
+	Offset         string  `json:"offset" structs:"offset"`
+	Size           uint64  `json:"size" structs:"size"`
+	VirtualAddress string  `json:"virtual_address" structs:"virtual_address"`
+	VirtualSize    uint64  `json:"virtual_size" structs:"virtual_size"`
+	Executable     bool    `json:"executable" structs:"executable"`
+	Writable       bool    `json:"writable" structs:"writable"`
+	Entropy        float64 `json:"entropy" structs:"entropy"`
+	address        uint64
+}
+
+// Overlay json object, the data past the end of the image
+type Overlay struct {
+	Offset  string  `json:"offset" structs:"offset"`
+	Size    uint64  `json:"size" structs:"size"`
+	Entropy float64 `json:"entropy" structs:"entropy"`
+}
+
+// image is what the packing heuristics need from an executable
+type image struct {
+	format   string
+	sections []Section
+	entry    uint64
+	// entryKnown is false for libraries and objects without an entry point
+	entryKnown bool
+	overlay    *Overlay
+	// segments is set when elf sections were replaced by segments
+	segments bool
+}
+
+// parseImage reads the sections of a pe or elf file, it returns nil for other files
+func parseImage(data []byte) (img *image) {
+
+	// debug/pe and debug/elf panic on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			log.Debugf("Error while parsing image: %v", r)
+			img = nil
+		}
+	}()
+
+	switch {
+	case bytes.HasPrefix(data, []byte("MZ")):
+		f, err := pe.NewFile(bytes.NewReader(data))
+		if err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing pe file"))
+			return nil
+		}
+		defer f.Close()
+		return peImage(f, data)
+	case bytes.HasPrefix(data, []byte(elf.ELFMAG)):
+		f, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing elf file"))
+			return nil
+		}
+		defer f.Close()
+		return elfImage(f, data)
+	}
+
+	return nil
+}
+
+func peImage(f *pe.File, data []byte) *image {
+
+	img := &image{format: "pe", sections: []Section{}}
+
+	var end uint64
+	var security pe.DataDirectory
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		img.entry, img.entryKnown = uint64(header.AddressOfEntryPoint), header.AddressOfEntryPoint != 0
+		end = uint64(header.SizeOfHeaders)
+		if header.NumberOfRvaAndSizes > directorySecurity {
+			security = header.DataDirectory[directorySecurity]
+		}
+	case *pe.OptionalHeader64:
+		img.entry, img.entryKnown = uint64(header.AddressOfEntryPoint), header.AddressOfEntryPoint != 0
+		end = uint64(header.SizeOfHeaders)
+		if header.NumberOfRvaAndSizes > directorySecurity {
+			security = header.DataDirectory[directorySecurity]
+		}
+	}
+
+	for _, s := range f.Sections {
+		img.sections = append(img.sections, Section{
+			Name:           s.Name,
+			Offset:         hexNumber(uint64(s.Offset)),
+			Size:           uint64(s.Size),
+			VirtualAddress: hexNumber(uint64(s.VirtualAddress)),
+			VirtualSize:    uint64(s.VirtualSize),
+			Executable:     s.Characteristics&(sectionCode|sectionExecute) != 0,
+			Writable:       s.Characteristics&sectionWrite != 0,
+			Entropy:        entropy(slice(data, uint64(s.Offset), uint64(s.Size))),
+			address:        uint64(s.VirtualAddress),
+		})
+		if s.Size != 0 && uint64(s.Offset)+uint64(s.Size) > end {
+			end = uint64(s.Offset) + uint64(s.Size)
+		}
+	}
+
+	// the authenticode signature is appended after the image but is not an overlay
+	overlayEnd := uint64(len(data))
+	if certificates := uint64(security.VirtualAddress); certificates >= end && certificates < overlayEnd && security.Size != 0 {
+		overlayEnd = certificates
+	}
+	img.overlay = overlay(data, end, overlayEnd)
+
+	return img
+}
+
+func elfImage(f *elf.File, data []byte) *image {
+
+	img := &image{format: "elf", sections: []Section{}, entry: f.Entry, entryKnown: f.Entry != 0}
+
+	end := sectionHeadersEnd(f, data)
+
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NULL {
+			continue
+		}
+		size := s.Size
+		if s.Type == elf.SHT_NOBITS {
+			size = 0
+		}
+		img.sections = append(img.sections, Section{
+			Name:           s.Name,
+			Offset:         hexNumber(s.Offset),
+			Size:           size,
+			VirtualAddress: hexNumber(s.Addr),
+			VirtualSize:    s.Size,
+			Executable:     s.Flags&elf.SHF_EXECINSTR != 0,
+			Writable:       s.Flags&elf.SHF_WRITE != 0,
+			Entropy:        entropy(slice(data, s.Offset, size)),
+			address:        s.Addr,
+		})
+		if e := extent(data, s.Offset, size); e > end {
+			end = e
+		}
+	}
+
+	for i, prog := range f.Progs {
+		if e := extent(data, prog.Off, prog.Filesz); e > end {
+			end = e
+		}
+		// packed files often strip their section headers, the loadable segments
+		// stand in for them
+		if len(f.Sections) == 0 && prog.Type == elf.PT_LOAD {
+			img.segments = true
+			img.sections = append(img.sections, Section{
+				Name:           fmt.Sprintf("LOAD[%d]", i),
+				Offset:         hexNumber(prog.Off),
+				Size:           prog.Filesz,
+				VirtualAddress: hexNumber(prog.Vaddr),
+				VirtualSize:    prog.Memsz,
+				Executable:     prog.Flags&elf.PF_X != 0,
+				Writable:       prog.Flags&elf.PF_W != 0,
+				Entropy:        entropy(slice(data, prog.Off, prog.Filesz)),
+				address:        prog.Vaddr,
+			})
+		}
+	}
+
+	img.overlay = overlay(data, end, uint64(len(data)))
+
+	return img
+}
+
+// sectionHeadersEnd returns the offset past the section header table, which
+// debug/elf does not expose
+func sectionHeadersEnd(f *elf.File, data []byte) uint64 {
+
+	var offset, size, count uint64
+	switch {
+	case f.Class == elf.ELFCLASS64 && len(data) >= 64:
+		offset = f.ByteOrder.Uint64(data[0x28:])
+		size, count = uint64(f.ByteOrder.Uint16(data[0x3a:])), uint64(f.ByteOrder.Uint16(data[0x3c:]))
+	case f.Class == elf.ELFCLASS32 && len(data) >= 52:
+		offset = uint64(f.ByteOrder.Uint32(data[0x20:]))
+		size, count = uint64(f.ByteOrder.Uint16(data[0x2e:])), uint64(f.ByteOrder.Uint16(data[0x30:]))
+	}
+	if offset == 0 || offset > uint64(len(data)) {
+		return 0
+	}
+
+	return offset + size*count
+}
+
+func overlay(data []byte, start uint64, end uint64) *Overlay {
+	if start >= end || end > uint64(len(data)) {
+		return nil
+	}
+	return &Overlay{
+		Offset:  hexNumber(start),
+		Size:    end - start,
+		Entropy: entropy(data[start:end]),
+	}
+}
+
+// extent returns offset+size with both clamped to the file length first, so
+// crafted values cannot wrap around
+func extent(data []byte, offset uint64, size uint64) uint64 {
+	length := uint64(len(data))
+	if offset > length {
+		offset = length
+	}
+	if size > length {
+		size = length
+	}
+	return offset + size
+}
+
+// slice returns data[offset:offset+size] clamped to the file
+func slice(data []byte, offset uint64, size uint64) []byte {
+	if offset >= uint64(len(data)) {
+		return nil
+	}
+	if size > uint64(len(data))-offset {
+		size = uint64(len(data)) - offset
+	}
+	return data[offset : offset+size]
+}
+
+func hexNumber(n uint64) string {
+	return fmt.Sprintf("0x%x", n)
+}
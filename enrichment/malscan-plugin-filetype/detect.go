@@ -0,0 +1,644 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// fileType is what a detector recognised, Type is the coarse family used for
+// routing and Subtype the specific format within it
+type fileType struct {
+	Type        string
+	Subtype     string
+	Category    string
+	MIME        string
+	Description string
+	Extensions  []string
+}
+
+var (
+	oleMagic      = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+	sevenZipMagic = []byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c}
+	lnkMagic      = []byte{0x4c, 0, 0, 0, 0x01, 0x14, 0x02, 0, 0, 0, 0, 0, 0xc0, 0, 0, 0, 0, 0, 0, 0x46}
+	pngMagic      = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+	msiCLSID      = []byte{0x84, 0x10, 0x0c, 0, 0, 0, 0, 0, 0xc0, 0, 0, 0, 0, 0, 0, 0x46}
+)
+
+// detectors are tried in order on the start of the file
+var detectors = []func([]byte) *fileType{
+	detectPE,
+	detectELF,
+	detectMachO,
+	detectOLE,
+	detectZip,
+	detectPDF,
+	detectRAR,
+	detectSevenZip,
+	detectSimple,
+	detectScript,
+}
+
+// detect identifies data, falling back to plain text or data
+func detect(data []byte) fileType {
+
+	if len(data) == 0 {
+		return fileType{Type: "empty", Category: "data", MIME: "application/x-empty", Description: "empty"}
+	}
+
+	for _, detector := range detectors {
+		if detected := detector(data); detected != nil {
+			return *detected
+		}
+	}
+
+	// a pdf header anywhere in the first kilobyte is still opened by readers
+	if offset := bytes.Index(head(data, 1024), []byte("%PDF-")); offset > 0 {
+		if detected := detectPDF(data[offset:]); detected != nil {
+			return *detected
+		}
+	}
+
+	if isText(data) {
+		return fileType{Type: "text", Subtype: "plain", Category: "text", MIME: "text/plain", Description: "text"}
+	}
+
+	return fileType{Type: "data", Category: "data", MIME: "application/octet-stream", Description: "data"}
+}
+
+// detectPE recognises pe images, and ms-dos executables without a pe header
+func detectPE(data []byte) *fileType {
+
+	if len(data) < 64 || !bytes.HasPrefix(data, []byte("MZ")) {
+		return nil
+	}
+
+	header := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	if header <= 0 || header+24 > len(data) || !bytes.Equal(data[header:header+4], []byte("PE\x00\x00")) {
+		return &fileType{
+			Type:        "dos",
+			Subtype:     "exe",
+			Category:    "executable",
+			MIME:        "application/x-dosexec",
+			Description: "MS-DOS executable",
+			Extensions:  []string{"exe", "com"},
+		}
+	}
+
+	machine := binary.LittleEndian.Uint16(data[header+4:])
+	characteristics := binary.LittleEndian.Uint16(data[header+22:])
+	optional := data[header+24:]
+
+	result := &fileType{
+		Type:       "pe",
+		Subtype:    "exe",
+		Category:   "executable",
+		MIME:       "application/x-dosexec",
+		Extensions: []string{"exe", "scr", "com", "pif", "cpl"},
+	}
+
+	format := "PE32"
+	directories := 96
+	if len(optional) >= 2 && binary.LittleEndian.Uint16(optional) == 0x20b {
+		format = "PE32+"
+		directories = 112
+	}
+
+	var subsystem uint16
+	if len(optional) >= 70 {
+		subsystem = binary.LittleEndian.Uint16(optional[68:])
+	}
+
+	// the clr runtime header directory marks .net assemblies
+	dotnet := false
+	if clr := directories + 14*8; len(optional) >= clr+8 {
+		dotnet = binary.LittleEndian.Uint32(optional[clr:]) != 0
+	}
+
+	kind := ""
+	switch {
+	case characteristics&0x2000 != 0:
+		result.Subtype = "dll"
+		result.Extensions = []string{"dll", "ocx", "cpl", "drv", "ax", "sys"}
+		kind = " (DLL)"
+	case subsystem == 1:
+		result.Subtype = "driver"
+		result.Extensions = []string{"sys", "drv"}
+	case subsystem >= 10 && subsystem <= 13:
+		result.Subtype = "efi"
+		result.Extensions = []string{"efi"}
+	}
+
+	result.Description = fmt.Sprintf("%s executable%s (%s) %s, for MS Windows", format, kind, lookup(peSubsystems, uint32(subsystem)), lookup(peMachines, uint32(machine)))
+	if dotnet {
+		result.Description += ", .NET assembly"
+	}
+
+	return result
+}
+
+// detectELF recognises elf files by their identification bytes
+func detectELF(data []byte) *fileType {
+
+	if len(data) < 52 || !bytes.HasPrefix(data, []byte("\x7fELF")) {
+		return nil
+	}
+
+	class, encoding := data[4], data[5]
+	if (class != 1 && class != 2) || (encoding != 1 && encoding != 2) {
+		return nil
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	bits, endian := "32-bit", "LSB"
+	if encoding == 2 {
+		order = binary.BigEndian
+		endian = "MSB"
+	}
+	if class == 2 {
+		bits = "64-bit"
+		if len(data) < 64 {
+			return nil
+		}
+	}
+
+	result := &fileType{Type: "elf", Category: "executable"}
+
+	switch order.Uint16(data[16:]) {
+	case 1:
+		result.Subtype, result.MIME, result.Extensions = "relocatable", "application/x-object", []string{"o", "ko"}
+	case 2:
+		result.Subtype, result.MIME, result.Extensions = "executable", "application/x-executable", []string{"elf", "bin", "out", "run"}
+	case 3:
+		// shared objects with an interpreter are position independent executables
+		if elfInterpreter(data, order, class == 2) {
+			result.Subtype, result.MIME, result.Extensions = "pie executable", "application/x-pie-executable", []string{"elf", "bin", "out", "run", "so"}
+		} else {
+			result.Subtype, result.MIME, result.Extensions = "shared object", "application/x-sharedlib", []string{"so", "ko"}
+		}
+	case 4:
+		result.Subtype, result.MIME, result.Extensions = "core file", "application/x-coredump", []string{"core"}
+	default:
+		result.Subtype, result.MIME = "unknown", "application/octet-stream"
+	}
+
+	result.Description = fmt.Sprintf("ELF %s %s %s, %s", bits, endian, result.Subtype, lookup(elfMachines, uint32(order.Uint16(data[18:]))))
+
+	return result
+}
+
+// elfInterpreter reports whether the program headers include PT_INTERP
+func elfInterpreter(data []byte, order binary.ByteOrder, is64 bool) bool {
+
+	var offset uint64
+	var size, count int
+	if is64 {
+		offset = order.Uint64(data[32:])
+		size, count = int(order.Uint16(data[54:])), int(order.Uint16(data[56:]))
+	} else {
+		offset = uint64(order.Uint32(data[28:]))
+		size, count = int(order.Uint16(data[42:])), int(order.Uint16(data[44:]))
+	}
+	if size < 4 || offset >= uint64(len(data)) {
+		return false
+	}
+
+	for i := 0; i < count; i++ {
+		entry := int(offset) + i*size
+		if entry+4 > len(data) {
+			return false
+		}
+		if order.Uint32(data[entry:]) == 3 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detectMachO recognises thin and universal mach-o files, telling universal
+// binaries apart from java classes which share their magic
+func detectMachO(data []byte) *fileType {
+
+	if len(data) < 8 {
+		return nil
+	}
+
+	magic := binary.BigEndian.Uint32(data)
+
+	if magic == 0xcafebabe || magic == 0xcafebabf {
+		count := binary.BigEndian.Uint32(data[4:])
+		if magic == 0xcafebabe && count >= 45 {
+			return &fileType{
+				Type:        "java",
+				Subtype:     "class",
+				Category:    "executable",
+				MIME:        "application/x-java-applet",
+				Description: fmt.Sprintf("compiled Java class data, version %d.%d", binary.BigEndian.Uint16(data[6:]), binary.BigEndian.Uint16(data[4:])),
+				Extensions:  []string{"class"},
+			}
+		}
+		if count == 0 || count >= 20 {
+			return nil
+		}
+		return &fileType{
+			Type:        "macho",
+			Subtype:     "universal",
+			Category:    "executable",
+			MIME:        "application/x-mach-binary",
+			Description: fmt.Sprintf("Mach-O universal binary with %d architectures", count),
+			Extensions:  []string{"dylib", "bundle", "so", "macho"},
+		}
+	}
+
+	var order binary.ByteOrder
+	bits := "32-bit"
+	switch magic {
+	case 0xfeedface:
+		order = binary.BigEndian
+	case 0xfeedfacf:
+		order, bits = binary.BigEndian, "64-bit"
+	case 0xcefaedfe:
+		order = binary.LittleEndian
+	case 0xcffaedfe:
+		order, bits = binary.LittleEndian, "64-bit"
+	default:
+		return nil
+	}
+	if len(data) < 16 {
+		return nil
+	}
+
+	kind := machoTypes[order.Uint32(data[12:])]
+	if len(kind.name) == 0 {
+		kind = machoTypes[0]
+	}
+
+	return &fileType{
+		Type:        "macho",
+		Subtype:     kind.name,
+		Category:    "executable",
+		MIME:        "application/x-mach-binary",
+		Description: fmt.Sprintf("Mach-O %s %s %s", bits, lookup(machoCPUs, order.Uint32(data[4:])), kind.name),
+		Extensions:  kind.extensions,
+	}
+}
+
+// detectOLE recognises compound files, using the stream names and root clsid
+// to tell the office formats apart
+func detectOLE(data []byte) *fileType {
+
+	if !bytes.HasPrefix(data, oleMagic) {
+		return nil
+	}
+
+	result := &fileType{
+		Type:        "ole",
+		Subtype:     "compound",
+		Category:    "document",
+		MIME:        "application/x-ole-storage",
+		Description: "Composite Document File V2 Document",
+	}
+
+	// the root entry is the first directory entry and carries the clsid
+	if len(data) >= 512 {
+		shift := uint(binary.LittleEndian.Uint16(data[0x1e:]))
+		sector := uint64(binary.LittleEndian.Uint32(data[0x30:]))
+		if shift >= 7 && shift <= 16 {
+			root := (sector + 1) << shift
+			if root+0x60 <= uint64(len(data)) && bytes.Equal(data[root+0x50:root+0x60], msiCLSID) {
+				result.Subtype, result.MIME, result.Extensions = "msi", "application/x-msi", []string{"msi"}
+				result.Description += ", MSI Installer"
+				return result
+			}
+		}
+	}
+
+	for _, format := range oleFormats {
+		if bytes.Contains(data, utf16(format.stream)) {
+			result.Subtype, result.MIME, result.Extensions = format.subtype, format.mime, format.extensions
+			result.Description += ", " + format.description
+			return result
+		}
+	}
+
+	return result
+}
+
+// detectZip recognises zip archives and the formats built on them from their entries
+func detectZip(data []byte) *fileType {
+
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) && !bytes.HasPrefix(data, []byte("PK\x05\x06")) {
+		return nil
+	}
+
+	result := &fileType{
+		Type:        "zip",
+		Subtype:     "zip",
+		Category:    "archive",
+		MIME:        "application/zip",
+		Description: "Zip archive data",
+		Extensions:  []string{"zip"},
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return result
+	}
+
+	entries := map[string]*zip.File{}
+	for _, f := range archive.File {
+		entries[f.Name] = f
+	}
+
+	if f, ok := entries["[Content_Types].xml"]; ok {
+		types := string(readEntry(f, 1<<20))
+		for _, format := range ooxmlFormats {
+			if strings.Contains(types, format.contentType) {
+				return &fileType{
+					Type:        "ooxml",
+					Subtype:     format.subtype,
+					Category:    "document",
+					MIME:        format.mime,
+					Description: format.description,
+					Extensions:  []string{format.subtype},
+				}
+			}
+		}
+		result.Type, result.Subtype, result.Category = "ooxml", "unknown", "document"
+		result.Description = "Microsoft OOXML"
+		return result
+	}
+
+	// open document files start with an uncompressed mimetype entry
+	if f, ok := entries["mimetype"]; ok {
+		mime := strings.TrimSpace(string(readEntry(f, 256)))
+		if strings.HasPrefix(mime, "application/vnd.oasis.opendocument.") {
+			subtype := openDocumentTypes[strings.TrimPrefix(mime, "application/vnd.oasis.opendocument.")]
+			return &fileType{
+				Type:        "odf",
+				Subtype:     subtype,
+				Category:    "document",
+				MIME:        mime,
+				Description: "OpenDocument " + strings.TrimPrefix(mime, "application/vnd.oasis.opendocument."),
+				Extensions:  []string{subtype},
+			}
+		}
+	}
+
+	if _, ok := entries["AndroidManifest.xml"]; ok {
+		if _, ok := entries["classes.dex"]; ok {
+			result.Subtype, result.Category, result.MIME = "apk", "executable", "application/vnd.android.package-archive"
+			result.Description, result.Extensions = "Android package (APK)", []string{"apk"}
+			return result
+		}
+	}
+
+	if _, ok := entries["META-INF/MANIFEST.MF"]; ok {
+		result.Subtype, result.Category, result.MIME = "jar", "executable", "application/java-archive"
+		result.Description, result.Extensions = "Java archive data (JAR)", []string{"jar", "war", "ear"}
+	}
+
+	return result
+}
+
+func detectPDF(data []byte) *fileType {
+
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return nil
+	}
+
+	version := ""
+	for _, c := range head(data[5:], 8) {
+		if (c < '0' || c > '9') && c != '.' {
+			break
+		}
+		version += string(c)
+	}
+
+	description := "PDF document"
+	if len(version) != 0 {
+		description += ", version " + version
+	}
+
+	return &fileType{
+		Type:        "pdf",
+		Subtype:     "pdf",
+		Category:    "document",
+		MIME:        "application/pdf",
+		Description: description,
+		Extensions:  []string{"pdf"},
+	}
+}
+
+func detectRAR(data []byte) *fileType {
+
+	if !bytes.HasPrefix(data, []byte("Rar!\x1a\x07")) {
+		return nil
+	}
+
+	version := "v4"
+	if len(data) > 7 && data[6] == 0x01 && data[7] == 0x00 {
+		version = "v5"
+	}
+
+	return &fileType{
+		Type:        "rar",
+		Subtype:     "rar",
+		Category:    "archive",
+		MIME:        "application/x-rar",
+		Description: "RAR archive data, " + version,
+		Extensions:  []string{"rar"},
+	}
+}
+
+func detectSevenZip(data []byte) *fileType {
+
+	if !bytes.HasPrefix(data, sevenZipMagic) || len(data) < 8 {
+		return nil
+	}
+
+	return &fileType{
+		Type:        "7z",
+		Subtype:     "7z",
+		Category:    "archive",
+		MIME:        "application/x-7z-compressed",
+		Description: fmt.Sprintf("7-zip archive data, version %d.%d", data[6], data[7]),
+		Extensions:  []string{"7z"},
+	}
+}
+
+// detectSimple recognises the formats identified by their magic alone
+func detectSimple(data []byte) *fileType {
+	for _, format := range simpleFormats {
+		if bytes.HasPrefix(data, format.magic) {
+			detected := format.fileType
+			return &detected
+		}
+	}
+	return nil
+}
+
+func readEntry(f *zip.File, limit int64) []byte {
+	r, err := f.Open()
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(r, limit))
+	return data
+}
+
+// utf16 encodes an ascii name as it appears in a compound file directory
+func utf16(name string) []byte {
+	encoded := make([]byte, 0, 2*len(name))
+	for i := 0; i < len(name); i++ {
+		encoded = append(encoded, name[i], 0)
+	}
+	return encoded
+}
+
+func head(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+	return data[:n]
+}
+
+func lookup(names map[uint32]string, value uint32) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (0x%x)", value)
+}
+
+var peMachines = map[uint32]string{
+	0x14c:  "Intel 80386",
+	0x8664: "x86-64",
+	0x1c0:  "ARM",
+	0x1c4:  "ARMv7 Thumb",
+	0xaa64: "Aarch64",
+	0x200:  "Intel Itanium",
+	0xebc:  "EFI byte code",
+}
+
+var peSubsystems = map[uint32]string{
+	0:  "unknown",
+	1:  "native",
+	2:  "GUI",
+	3:  "console",
+	7:  "POSIX",
+	9:  "Windows CE GUI",
+	10: "EFI application",
+	11: "EFI boot service driver",
+	12: "EFI runtime driver",
+	13: "EFI ROM",
+	16: "Windows boot application",
+}
+
+var elfMachines = map[uint32]string{
+	2:   "SPARC",
+	3:   "Intel 80386",
+	4:   "Motorola m68k",
+	8:   "MIPS",
+	20:  "PowerPC",
+	21:  "64-bit PowerPC",
+	22:  "IBM S/390",
+	40:  "ARM",
+	42:  "Renesas SH",
+	43:  "SPARC V9",
+	62:  "x86-64",
+	183: "ARM aarch64",
+	243: "RISC-V",
+}
+
+var machoCPUs = map[uint32]string{
+	7:          "i386",
+	0x01000007: "x86_64",
+	12:         "arm",
+	0x0100000c: "arm64",
+	0x0200000c: "arm64_32",
+	18:         "ppc",
+	0x01000012: "ppc64",
+}
+
+var machoTypes = map[uint32]struct {
+	name       string
+	extensions []string
+}{
+	0:  {"unknown", []string{"macho"}},
+	1:  {"object", []string{"o"}},
+	2:  {"executable", []string{"macho", "app"}},
+	4:  {"core", []string{"core"}},
+	6:  {"dylib", []string{"dylib", "so"}},
+	7:  {"dylinker", []string{"dylib"}},
+	8:  {"bundle", []string{"bundle", "so"}},
+	11: {"kext bundle", []string{"kext"}},
+}
+
+// oleFormats are matched by their characteristic stream name
+var oleFormats = []struct {
+	stream      string
+	subtype     string
+	mime        string
+	description string
+	extensions  []string
+}{
+	{"WordDocument", "doc", "application/msword", "Microsoft Word document", []string{"doc", "dot"}},
+	{"Workbook", "xls", "application/vnd.ms-excel", "Microsoft Excel workbook", []string{"xls", "xlt", "xla"}},
+	{"Book", "xls", "application/vnd.ms-excel", "Microsoft Excel workbook", []string{"xls", "xlt", "xla"}},
+	{"PowerPoint Document", "ppt", "application/vnd.ms-powerpoint", "Microsoft PowerPoint presentation", []string{"ppt", "pps", "pot"}},
+	{"__substg1.0_", "msg", "application/vnd.ms-outlook", "Microsoft Outlook message", []string{"msg"}},
+	{"VisioDocument", "vsd", "application/vnd.visio", "Microsoft Visio drawing", []string{"vsd"}},
+	{"Quill", "pub", "application/vnd.ms-publisher", "Microsoft Publisher document", []string{"pub"}},
+}
+
+// ooxmlFormats are matched by the main part's content type
+var ooxmlFormats = []struct {
+	contentType string
+	subtype     string
+	mime        string
+	description string
+}{
+	{"ms-word.document.macroEnabled.main+xml", "docm", "application/vnd.ms-word.document.macroEnabled.12", "Microsoft Word 2007+ macro-enabled document"},
+	{"ms-word.template.macroEnabledTemplate.main+xml", "dotm", "application/vnd.ms-word.template.macroEnabled.12", "Microsoft Word 2007+ macro-enabled template"},
+	{"wordprocessingml.document.main+xml", "docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", "Microsoft Word 2007+"},
+	{"wordprocessingml.template.main+xml", "dotx", "application/vnd.openxmlformats-officedocument.wordprocessingml.template", "Microsoft Word 2007+ template"},
+	{"ms-excel.sheet.macroEnabled.main+xml", "xlsm", "application/vnd.ms-excel.sheet.macroEnabled.12", "Microsoft Excel 2007+ macro-enabled workbook"},
+	{"ms-excel.sheet.binary.macroEnabled.main", "xlsb", "application/vnd.ms-excel.sheet.binary.macroEnabled.12", "Microsoft Excel 2007+ binary workbook"},
+	{"ms-excel.template.macroEnabled.main+xml", "xltm", "application/vnd.ms-excel.template.macroEnabled.12", "Microsoft Excel 2007+ macro-enabled template"},
+	{"ms-excel.addin.macroEnabled.main+xml", "xlam", "application/vnd.ms-excel.addin.macroEnabled.12", "Microsoft Excel 2007+ add-in"},
+	{"spreadsheetml.sheet.main+xml", "xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "Microsoft Excel 2007+"},
+	{"spreadsheetml.template.main+xml", "xltx", "application/vnd.openxmlformats-officedocument.spreadsheetml.template", "Microsoft Excel 2007+ template"},
+	{"ms-powerpoint.presentation.macroEnabled.main+xml", "pptm", "application/vnd.ms-powerpoint.presentation.macroEnabled.12", "Microsoft PowerPoint 2007+ macro-enabled presentation"},
+	{"ms-powerpoint.slideshow.macroEnabled.main+xml", "ppsm", "application/vnd.ms-powerpoint.slideshow.macroEnabled.12", "Microsoft PowerPoint 2007+ macro-enabled slideshow"},
+	{"presentationml.presentation.main+xml", "pptx", "application/vnd.openxmlformats-officedocument.presentationml.presentation", "Microsoft PowerPoint 2007+"},
+	{"presentationml.slideshow.main+xml", "ppsx", "application/vnd.openxmlformats-officedocument.presentationml.slideshow", "Microsoft PowerPoint 2007+ slideshow"},
+	{"presentationml.template.main+xml", "potx", "application/vnd.openxmlformats-officedocument.presentationml.template", "Microsoft PowerPoint 2007+ template"},
+}
+
+var openDocumentTypes = map[string]string{
+	"text":         "odt",
+	"spreadsheet":  "ods",
+	"presentation": "odp",
+	"graphics":     "odg",
+	"formula":      "odf",
+}
+
+var simpleFormats = []struct {
+	magic []byte
+	fileType
+}{
+	{[]byte("{\\rt"), fileType{"rtf", "rtf", "document", "text/rtf", "Rich Text Format data", []string{"rtf", "doc"}}},
+	{lnkMagic, fileType{"lnk", "lnk", "shortcut", "application/x-ms-shortcut", "MS Windows shortcut", []string{"lnk"}}},
+	{[]byte("MSCF\x00\x00\x00\x00"), fileType{"cab", "cab", "archive", "application/vnd.ms-cab-compressed", "Microsoft Cabinet archive data", []string{"cab"}}},
+	{[]byte{0x1f, 0x8b, 0x08}, fileType{"gzip", "gzip", "archive", "application/gzip", "gzip compressed data", []string{"gz", "tgz"}}},
+	{pngMagic, fileType{"image", "png", "image", "image/png", "PNG image data", []string{"png"}}},
+	{[]byte{0xff, 0xd8, 0xff}, fileType{"image", "jpeg", "image", "image/jpeg", "JPEG image data", []string{"jpg", "jpeg", "jpe"}}},
+	{[]byte("GIF87a"), fileType{"image", "gif", "image", "image/gif", "GIF image data, version 87a", []string{"gif"}}},
+	{[]byte("GIF89a"), fileType{"image", "gif", "image", "image/gif", "GIF image data, version 89a", []string{"gif"}}},
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-filetype/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "filetype"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// FileType json object (this is what gets output)
+type FileType struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Type               string
+	Subtype            string
+	Category           string
+	MIME               string
+	Description        string
+	Extension          string
+	ExpectedExtensions []string
+	ExtensionMismatch  bool
+	Magic              *Magic
+	Polyglot           bool
+	Indicators         []Indicator
+	Error              string `json:"error" structs:"error"`
+}
+
+// Magic json object, what libmagic makes of the file
+type Magic struct {
+	MIME        string `json:"mime" structs:"mime"`
+	Description string `json:"description" structs:"description"`
+}
+
+// AvScan identifies the file, giving up after timeout seconds
+func AvScan(timeout int, magicFile string) FileType {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		done <- identify(ctx, magicFile)
+	}()
+
+	select {
+	case results := <-done:
+		return FileType{Results: results}
+	case <-ctx.Done():
+		return FileType{Results: ResultsData{Error: fmt.Sprintf("identification of %s timed out", path)}}
+	}
+}
+
+// identify runs the built in detectors and libmagic on the file, the detectors
+// decide the type and libmagic fills in files they do not recognise
+func identify(ctx context.Context, magicFile string) ResultsData {
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return ResultsData{Error: err.Error()}
+	}
+
+	detected := detect(data)
+
+	results := ResultsData{
+		Type:               detected.Type,
+		Subtype:            detected.Subtype,
+		Category:           detected.Category,
+		MIME:               detected.MIME,
+		Description:        detected.Description,
+		Extension:          extension(path),
+		ExpectedExtensions: detected.Extensions,
+		Magic:              libmagic(ctx, magicFile),
+		Indicators:         indicators(data, detected),
+		Error:              "nil",
+	}
+
+	if results.Magic != nil && (results.Type == "data" || results.Type == "text") {
+		results.MIME = results.Magic.MIME
+		results.Description = results.Magic.Description
+	}
+
+	if len(results.Extension) != 0 && len(results.ExpectedExtensions) != 0 {
+		results.ExtensionMismatch = !contains(results.ExpectedExtensions, results.Extension)
+	}
+
+	for _, indicator := range results.Indicators {
+		if polyglotIndicators[indicator.Type] {
+			results.Polyglot = true
+		}
+	}
+
+	return results
+}
+
+// libmagic asks file(1) for the description and mime type, magicFile replaces
+// the system database when set, nil means file is not installed or failed
+func libmagic(ctx context.Context, magicFile string) *Magic {
+
+	args := []string{"--brief"}
+	if len(magicFile) != 0 {
+		args = append(args, "--magic-file", magicFile)
+	}
+
+	description, err := utils.RunCommand(ctx, "file", append(args, path)...)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while running file command"))
+		return nil
+	}
+	mime, err := utils.RunCommand(ctx, "file", append(args, "--mime-type", path)...)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while running file command"))
+		return nil
+	}
+
+	return &Magic{
+		MIME:        strings.TrimSpace(mime),
+		Description: strings.TrimSpace(description),
+	}
+}
+
+// extension returns the lower case extension without its dot, skipping
+// numeric version suffixes such as libc.so.6
+func extension(file string) string {
+
+	base := filepath.Base(file)
+	for {
+		ext := filepath.Ext(base)
+		if len(ext) < 2 {
+			return ""
+		}
+		if strings.Trim(ext[1:], "0123456789") != "" {
+			return strings.ToLower(ext[1:])
+		}
+		base = strings.TrimSuffix(base, ext)
+	}
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "FileType"
+	app.Usage = "Malscan file type plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+		cli.StringFlag{
+			Name:   "magic, m",
+			Usage:  "libmagic database to use instead of the system one",
+			EnvVar: "MALSCAN_FILETYPE_MAGIC",
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			filetype := AvScan(c.Int("timeout"), c.String("magic"))
+
+			// convert to JSON
+			filetypeJSON, _ := json.Marshal(filetype)
+
+			fmt.Println(string(filetypeJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
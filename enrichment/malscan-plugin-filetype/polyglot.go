@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// maxIndicators caps how many embedded files of one kind are reported
+const maxIndicators = 16
+
+// Indicator json object, something found past the start of the file
+type Indicator struct {
+	Type        string `json:"type" structs:"type"`
+	Offset      int    `json:"offset" structs:"offset"`
+	Description string `json:"description" structs:"description"`
+}
+
+// polyglotIndicators are the indicators that make the file valid as a second
+// format, the rest only show content carried inside it
+var polyglotIndicators = map[string]bool{
+	"pdf_header":   true,
+	"appended_zip": true,
+	"markup":       true,
+}
+
+// embeddedSignatures are searched for everywhere past the start of the file,
+// valid checks the header that follows so magics quoted as data are skipped
+var embeddedSignatures = []struct {
+	name        string
+	magic       []byte
+	valid       func(data []byte, offset int) bool
+	description string
+}{
+	{"pe", []byte("MZ"), validPE, "PE executable"},
+	{"elf", []byte("\x7fELF"), validELF, "ELF executable"},
+	{"ole", oleMagic, validOLE, "OLE compound file"},
+	{"zip", []byte("PK\x03\x04"), validZip, "zip archive"},
+	{"rar", []byte("Rar!\x1a\x07"), validRAR, "RAR archive"},
+	{"7z", sevenZipMagic, validSevenZip, "7-zip archive"},
+	{"cab", []byte("MSCF\x00\x00\x00\x00"), validCab, "cabinet archive"},
+	{"pdf", []byte("%PDF-"), validPDF, "PDF document"},
+}
+
+var markupSignatures = [][]byte{
+	[]byte("<script"),
+	[]byte("<html"),
+	[]byte("<?php"),
+	[]byte("<hta:application"),
+	[]byte("<iframe"),
+}
+
+// indicators lists the files embedded in data, other formats it is also valid
+// as and data trailing its logical end
+func indicators(data []byte, detected fileType) []Indicator {
+
+	found := []Indicator{}
+
+	for _, signature := range embeddedSignatures {
+		// containers hold their own signature throughout, executables carrying
+		// executables are droppers worth reporting
+		if signature.name == detected.Type && signature.name != "pe" {
+			continue
+		}
+		if signature.name == "zip" && zipFamily(detected.Type) {
+			continue
+		}
+
+		count := 0
+		for offset := 1; offset < len(data) && count < maxIndicators; {
+			i := bytes.Index(data[offset:], signature.magic)
+			if i < 0 {
+				break
+			}
+			offset += i
+			if signature.valid(data, offset) {
+				indicator := Indicator{Type: "embedded_" + signature.name, Offset: offset, Description: fmt.Sprintf("%s at offset 0x%x", signature.description, offset)}
+				// readers accept a pdf header anywhere in the first kilobyte
+				if signature.name == "pdf" && offset < 1024 && detected.Type != "pdf" {
+					indicator.Type = "pdf_header"
+					indicator.Description = fmt.Sprintf("PDF header at offset 0x%x, the file also opens as a PDF document", offset)
+				}
+				found = append(found, indicator)
+				count++
+				// one zip entry is enough to show an archive is there
+				if signature.name == "zip" {
+					break
+				}
+			}
+			offset += len(signature.magic)
+		}
+	}
+
+	// junk before the header hides a pdf from tools that only look at the start
+	if detected.Type == "pdf" && !bytes.HasPrefix(data, []byte("%PDF-")) {
+		offset := bytes.Index(data, []byte("%PDF-"))
+		found = append(found, Indicator{Type: "pdf_offset", Offset: offset, Description: fmt.Sprintf("%d bytes before the PDF header", offset)})
+	}
+
+	// zip readers work from the end of central directory, so an archive appended
+	// to any other file opens as a zip
+	if !zipFamily(detected.Type) {
+		tail := 0
+		if len(data) > 65557 {
+			tail = len(data) - 65557
+		}
+		if i := bytes.LastIndex(data[tail:], []byte("PK\x05\x06")); i >= 0 && tail+i > 0 && validEndOfCentralDirectory(data, tail+i) {
+			found = append(found, Indicator{Type: "appended_zip", Offset: tail + i, Description: fmt.Sprintf("zip end of central directory at offset 0x%x, the file also opens as a zip archive", tail+i)})
+		}
+	}
+
+	// html or script markup inside an image turns it into a web polyglot
+	if detected.Category == "image" {
+		lower := bytes.ToLower(data)
+		for _, markup := range markupSignatures {
+			if i := bytes.Index(lower, markup); i >= 0 {
+				found = append(found, Indicator{Type: "markup", Offset: i, Description: fmt.Sprintf("%s markup at offset 0x%x", markup, i)})
+			}
+		}
+	}
+
+	if end := logicalEnd(data, detected); end > 0 && end < len(data) {
+		found = append(found, Indicator{Type: "trailing_data", Offset: end, Description: fmt.Sprintf("%d bytes after the end of the %s", len(data)-end, detected.Subtype)})
+	}
+
+	return found
+}
+
+// logicalEnd returns where a png or pdf ends, ignoring trailing whitespace and
+// padding, or 0 when unknown
+func logicalEnd(data []byte, detected fileType) int {
+
+	var end int
+	switch detected.Subtype {
+	case "png":
+		i := bytes.LastIndex(data, []byte("IEND"))
+		if i < 0 {
+			return 0
+		}
+		// the chunk type is followed by its crc
+		end = i + 8
+	case "pdf":
+		i := bytes.LastIndex(data, []byte("%%EOF"))
+		if i < 0 {
+			return 0
+		}
+		end = i + 5
+	default:
+		return 0
+	}
+
+	if end >= len(data) || len(bytes.Trim(data[end:], "\x00\r\n\t ")) == 0 {
+		return 0
+	}
+
+	return end
+}
+
+func zipFamily(fileType string) bool {
+	return fileType == "zip" || fileType == "ooxml" || fileType == "odf"
+}
+
+// validPE checks an MZ header points at a PE signature
+func validPE(data []byte, offset int) bool {
+	if offset+64 > len(data) {
+		return false
+	}
+	header := offset + int(binary.LittleEndian.Uint32(data[offset+0x3c:]))
+	return header > offset && header+4 <= len(data) && bytes.Equal(data[header:header+4], []byte("PE\x00\x00"))
+}
+
+// validELF checks the elf identification bytes after the magic
+func validELF(data []byte, offset int) bool {
+	if offset+7 > len(data) {
+		return false
+	}
+	class, encoding, version := data[offset+4], data[offset+5], data[offset+6]
+	return (class == 1 || class == 2) && (encoding == 1 || encoding == 2) && version == 1
+}
+
+// validOLE checks the compound file's byte order mark, version and sector size
+func validOLE(data []byte, offset int) bool {
+	if offset+0x20 > len(data) {
+		return false
+	}
+	header := data[offset:]
+	major := binary.LittleEndian.Uint16(header[0x1a:])
+	shift := binary.LittleEndian.Uint16(header[0x1e:])
+	return (major == 3 && shift == 9 || major == 4 && shift == 12) && binary.LittleEndian.Uint16(header[0x1c:]) == 0xfffe
+}
+
+// validZip checks a local file header has a known compression method and a name
+func validZip(data []byte, offset int) bool {
+	if offset+30 > len(data) {
+		return false
+	}
+	header := data[offset:]
+	method := binary.LittleEndian.Uint16(header[8:])
+	nameLength := int(binary.LittleEndian.Uint16(header[26:]))
+	knownMethod := method == 0 || method == 8 || method == 9 || method == 12 || method == 14 || method == 93 || method == 95 || method == 98 || method == 99
+	return knownMethod && binary.LittleEndian.Uint16(header[4:]) < 100 && nameLength != 0 && offset+30+nameLength <= len(data)
+}
+
+// validEndOfCentralDirectory checks the record's comment ends within the file
+func validEndOfCentralDirectory(data []byte, offset int) bool {
+	if offset+22 > len(data) {
+		return false
+	}
+	return offset+22+int(binary.LittleEndian.Uint16(data[offset+20:])) <= len(data)
+}
+
+// validRAR checks the marker is followed by a main archive header
+func validRAR(data []byte, offset int) bool {
+	if offset+14 > len(data) {
+		return false
+	}
+	header := data[offset:]
+	switch {
+	case header[6] == 0x00:
+		return header[9] == 0x73
+	case header[6] == 0x01 && header[7] == 0x00:
+		// crc32, a one byte header size and the main header type
+		return header[13] == 0x01
+	}
+	return false
+}
+
+// validSevenZip checks the format version
+func validSevenZip(data []byte, offset int) bool {
+	return offset+8 <= len(data) && data[offset+6] == 0 && data[offset+7] <= 4
+}
+
+// validCab checks the cabinet size, reserved field and format version
+func validCab(data []byte, offset int) bool {
+	if offset+26 > len(data) {
+		return false
+	}
+	header := data[offset:]
+	return binary.LittleEndian.Uint32(header[8:]) != 0 && binary.LittleEndian.Uint32(header[12:]) == 0 && header[24] == 3 && header[25] == 1
+}
+
+// validPDF checks the header carries a version number
+func validPDF(data []byte, offset int) bool {
+	if offset+8 > len(data) {
+		return false
+	}
+	version := data[offset+5:]
+	return version[0] >= '1' && version[0] <= '2' && version[1] == '.' && version[2] >= '0' && version[2] <= '9'
+}
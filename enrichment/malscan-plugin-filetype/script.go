@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// scriptSample is how much of a text file is read to recognise its language
+const scriptSample = 64 * 1024
+
+type scriptLanguage struct {
+	fileType
+	markers []scriptMarker
+}
+
+// scriptMarker is a lower case substring and how strongly it suggests its language
+type scriptMarker struct {
+	text   string
+	weight int
+}
+
+// detectScript recognises scripts from their shebang, or from the language
+// markers in their text when they have none
+func detectScript(data []byte) *fileType {
+
+	text, ok := textSample(data)
+	if !ok {
+		return nil
+	}
+
+	if bytes.HasPrefix(text, []byte("#!")) {
+		line := text[2:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		if language, ok := shebangLanguages[shebangInterpreter(string(line))]; ok {
+			return scriptType(language)
+		}
+	}
+
+	lower := bytes.ToLower(text)
+
+	// markers that settle the language on their own
+	for _, decisive := range decisiveMarkers {
+		if bytes.Contains(lower, []byte(decisive.text)) {
+			return scriptType(decisive.language)
+		}
+	}
+
+	best, bestScore := "", 0
+	for _, language := range scriptLanguageOrder {
+		score := 0
+		for _, marker := range scriptLanguages[language].markers {
+			if bytes.Contains(lower, []byte(marker.text)) {
+				score += marker.weight
+			}
+		}
+		if score > bestScore {
+			best, bestScore = language, score
+		}
+	}
+	if bestScore < 3 {
+		return nil
+	}
+
+	return scriptType(best)
+}
+
+func scriptType(language string) *fileType {
+	detected := scriptLanguages[language].fileType
+	return &detected
+}
+
+// shebangInterpreter returns the interpreter's name without its version, looking
+// through env
+func shebangInterpreter(line string) string {
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+
+	return strings.TrimRight(interpreter, "0123456789.")
+}
+
+// textSample returns the start of the file as text, decoding utf-16 and dropping
+// byte order marks, or false when the file is not text
+func textSample(data []byte) ([]byte, bool) {
+
+	sample := head(data, scriptSample)
+
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xef, 0xbb, 0xbf}):
+		sample = sample[3:]
+	case bytes.HasPrefix(sample, []byte{0xff, 0xfe}):
+		sample = fromUTF16(sample[2:], 0)
+	case bytes.HasPrefix(sample, []byte{0xfe, 0xff}):
+		sample = fromUTF16(sample[2:], 1)
+	}
+
+	if len(sample) == 0 {
+		return nil, false
+	}
+
+	control := 0
+	for _, c := range sample {
+		if c == 0 {
+			return nil, false
+		}
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' && c != 0x1b {
+			control++
+		}
+	}
+
+	return sample, control*100 <= len(sample)
+}
+
+func isText(data []byte) bool {
+	_, ok := textSample(data)
+	return ok
+}
+
+// fromUTF16 keeps the low byte of every character, which is enough to match
+// the ascii markers, text outside latin-1 becomes a question mark
+func fromUTF16(data []byte, low int) []byte {
+
+	text := make([]byte, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i+1-low] != 0 {
+			text = append(text, '?')
+			continue
+		}
+		text = append(text, data[i+low])
+	}
+
+	return text
+}
+
+var shebangLanguages = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"dash":    "shell",
+	"zsh":     "shell",
+	"ksh":     "shell",
+	"ash":     "shell",
+	"busybox": "shell",
+	"python":  "python",
+	"perl":    "perl",
+	"ruby":    "ruby",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"php":     "php",
+	"pwsh":    "powershell",
+	"lua":     "lua",
+}
+
+var decisiveMarkers = []struct {
+	text     string
+	language string
+}{
+	{"<?php", "php"},
+	{"<hta:application", "hta"},
+	{"#@~^", "encoded"},
+}
+
+// scriptLanguageOrder breaks ties in favour of the earlier language
+var scriptLanguageOrder = []string{"powershell", "vbscript", "javascript", "batch", "python", "shell", "perl", "html"}
+
+var scriptLanguages = map[string]scriptLanguage{
+	"powershell": {
+		fileType{"script", "powershell", "script", "text/x-powershell", "PowerShell script text", []string{"ps1", "psm1", "psd1"}},
+		[]scriptMarker{
+			{"invoke-expression", 3}, {"set-executionpolicy", 3}, {"$psscriptroot", 3}, {"$env:", 2},
+			{"new-object", 2}, {"[system.", 2}, {"[convert]::", 2}, {"write-host", 2}, {"start-process", 2},
+			{"foreach-object", 2}, {"where-object", 2}, {"-bxor", 2}, {"param(", 1}, {"downloadstring", 1},
+			{"frombase64string", 1}, {"$_", 1},
+		},
+	},
+	"vbscript": {
+		fileType{"script", "vbscript", "script", "text/vbscript", "VBScript text", []string{"vbs", "vba", "bas"}},
+		[]scriptMarker{
+			{"on error resume next", 3}, {"wscript.createobject", 2}, {"createobject(", 2}, {"end sub", 2},
+			{"end function", 2}, {"vbcrlf", 2}, {"dim ", 1}, {"msgbox", 1}, {"execute(", 1}, {"chr(", 1},
+			{"wscript.", 1},
+		},
+	},
+	"javascript": {
+		fileType{"script", "javascript", "script", "application/javascript", "JavaScript text", []string{"js", "jse", "mjs", "cjs"}},
+		[]scriptMarker{
+			{"activexobject", 3}, {"document.write", 2}, {"console.log", 2}, {"string.fromcharcode", 2},
+			{".prototype.", 2}, {"function(", 1}, {"function ", 1}, {"var ", 1}, {"eval(", 1}, {"window.", 1},
+			{"require(", 1}, {"=>", 1}, {"});", 1}, {"wscript.", 1},
+		},
+	},
+	"batch": {
+		fileType{"script", "batch", "script", "text/x-msdos-batch", "DOS batch file text", []string{"bat", "cmd"}},
+		[]scriptMarker{
+			{"@echo off", 3}, {"%~dp0", 3}, {"setlocal", 2}, {"%errorlevel%", 2}, {"set /a", 2}, {"set /p", 2},
+			{"if exist", 2}, {"%comspec%", 2}, {"start \"\"", 2}, {"echo ", 1}, {"goto ", 1}, {"cmd /c", 1},
+			{"rem ", 1}, {"%temp%", 1}, {"%appdata%", 1},
+		},
+	},
+	"python": {
+		fileType{"script", "python", "script", "text/x-python", "Python script text", []string{"py", "pyw"}},
+		[]scriptMarker{
+			{"__name__", 2}, {"__init__", 2}, {"import os", 2}, {"import sys", 2}, {"elif ", 2},
+			{"import ", 1}, {"def ", 1}, {"print(", 1}, {"self.", 1},
+		},
+	},
+	"shell": {
+		fileType{"script", "shell", "script", "text/x-shellscript", "shell script text", []string{"sh", "bash"}},
+		[]scriptMarker{
+			{"; then", 2}, {"esac", 2}, {"chmod +x", 2}, {"\nfi", 1}, {"/dev/null", 1}, {"$(", 1},
+			{"wget ", 1}, {"curl ", 1}, {"export ", 1},
+		},
+	},
+	"perl": {
+		fileType{"script", "perl", "script", "text/x-perl", "Perl script text", []string{"pl", "pm"}},
+		[]scriptMarker{{"use strict", 3}, {"my $", 2}, {"sub ", 1}},
+	},
+	"html": {
+		fileType{"html", "html", "document", "text/html", "HTML document text", []string{"html", "htm", "xhtml", "svg"}},
+		[]scriptMarker{{"<!doctype html", 3}, {"<html", 3}, {"<head", 1}, {"<body", 1}, {"<script", 1}},
+	},
+	"php": {
+		fileType{"script", "php", "script", "text/x-php", "PHP script text", []string{"php", "phtml", "inc"}},
+		nil,
+	},
+	"hta": {
+		fileType{"script", "hta", "script", "application/hta", "HTML application text", []string{"hta", "html", "htm"}},
+		nil,
+	},
+	"encoded": {
+		fileType{"script", "encoded", "script", "text/plain", "Microsoft encoded script text", []string{"vbe", "jse"}},
+		nil,
+	},
+	"ruby": {
+		fileType{"script", "ruby", "script", "text/x-ruby", "Ruby script text", []string{"rb"}},
+		nil,
+	},
+	"lua": {
+		fileType{"script", "lua", "script", "text/x-lua", "Lua script text", []string{"lua"}},
+		nil,
+	},
+}
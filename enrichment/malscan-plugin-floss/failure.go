@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+// Error codes reported in ResultsData.ErrorCode
+const (
+	errUnsupportedFormat = "unsupported_format"
+	errCorruptedFile     = "corrupted_file"
+	errTimeout           = "timeout"
+	errEngineCrash       = "engine_crash"
+	errJSONDecode        = "json_decode"
+)
+
+// classifyFailure maps a failed floss run to an error code and the reason floss gave for exiting
+func classifyFailure(ctx context.Context, err error, stderr string) (code string, reason string) {
+
+	reason = lastLine(stderr)
+	if len(reason) == 0 {
+		reason = err.Error()
+	}
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errTimeout, reason
+	}
+
+	lower := strings.ToLower(stderr)
+	switch {
+	case strings.Contains(lower, "does not appear to be"),
+		strings.Contains(lower, "unsupported file"),
+		strings.Contains(lower, "supports the following formats"):
+		return errUnsupportedFormat, reason
+	case strings.Contains(lower, "corrupt"),
+		strings.Contains(lower, "peformaterror"),
+		strings.Contains(lower, "invalid pe"):
+		return errCorruptedFile, reason
+	}
+
+	return errEngineCrash, reason
+}
+
+// lastLine returns the last non-empty line of output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// IOCs - holds the indicators found in the extracted strings
+type IOCs struct {
+	URLs         []IOC `json:"urls,omitempty" structs:"urls"`
+	Domains      []IOC `json:"domains,omitempty" structs:"domains"`
+	IPv4         []IOC `json:"ipv4,omitempty" structs:"ipv4"`
+	IPv6         []IOC `json:"ipv6,omitempty" structs:"ipv6"`
+	Emails       []IOC `json:"emails,omitempty" structs:"emails"`
+	Paths        []IOC `json:"paths,omitempty" structs:"paths"`
+	RegistryKeys []IOC `json:"registry_keys,omitempty" structs:"registry_keys"`
+	Mutexes      []IOC `json:"mutexes,omitempty" structs:"mutexes"`
+	Wallets      []IOC `json:"wallets,omitempty" structs:"wallets"`
+	Base64       []IOC `json:"base64,omitempty" structs:"base64"`
+
+	seen map[string]bool
+}
+
+// IOC - holds one indicator with the offset and type of the string it was found in
+type IOC struct {
+	Value  string `json:"value" structs:"value"`
+	Offset string `json:"offset,omitempty" structs:"offset"`
+	Source string `json:"source" structs:"source"`
+	Kind   string `json:"kind,omitempty" structs:"kind"`
+}
+
+var (
+	urlPattern      = regexp.MustCompile(`(?i)\b(?:https?|ftp)://[^\s"'<>\x60]+`)
+	emailPattern    = regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+([a-z]{2,63}))\b`)
+	domainPattern   = regexp.MustCompile(`(?i)\b(?:[a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+([a-z]{2,63})\b`)
+	ipv4Pattern     = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Pattern     = regexp.MustCompile(`(?i)[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}`)
+	windowsPath     = regexp.MustCompile(`(?i)(?:\b[a-z]:\\|%[a-z_]+%\\|\\\\[a-z0-9._-]+\\)[^\s"*?<>|]+`)
+	unixPath        = regexp.MustCompile(`(?:^|[\s"'=])(/(?:bin|boot|dev|etc|home|lib|lib64|opt|proc|root|run|sbin|sys|tmp|usr|var)/[\w./-]*)`)
+	registryPattern = regexp.MustCompile(`(?i)\b(?:HKEY_LOCAL_MACHINE|HKEY_CURRENT_USER|HKEY_CLASSES_ROOT|HKEY_USERS|HKEY_CURRENT_CONFIG|HKLM|HKCU|HKCR|HKU)\\[^\s"]+|\b(?:SOFTWARE|SYSTEM)\\(?:Microsoft|CurrentControlSet|Classes|Policies|Wow6432Node)\\[^\s"]+`)
+	mutexPattern    = regexp.MustCompile(`\b(?:Global|Local|Session\\\d+)\\[^\s\\"]+`)
+	btcPattern      = regexp.MustCompile(`\b[13][1-9A-HJ-NP-Za-km-z]{25,34}\b`)
+	bech32Pattern   = regexp.MustCompile(`\bbc1[02-9ac-hj-np-z]{11,71}\b`)
+	ethPattern      = regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`)
+	xmrPattern      = regexp.MustCompile(`\b4[0-9AB][1-9A-HJ-NP-Za-km-z]{93}\b`)
+	base64Pattern   = regexp.MustCompile(`[A-Za-z0-9+/]{24,}={0,2}`)
+)
+
+// extract classifies the indicators in str and records each one once
+func (iocs *IOCs) extract(str String) {
+
+	value := str.String
+
+	for _, url := range urlPattern.FindAllString(value, -1) {
+		iocs.add(&iocs.URLs, str, "url", "", strings.TrimRight(url, ".,;)"))
+	}
+
+	for _, match := range emailPattern.FindAllStringSubmatch(value, -1) {
+		if validTLD(match[2]) {
+			iocs.add(&iocs.Emails, str, "email", "", match[0])
+		}
+	}
+
+	for _, match := range domainPattern.FindAllStringSubmatch(value, -1) {
+		if validDomain(match[0], match[1]) {
+			iocs.add(&iocs.Domains, str, "domain", "", strings.ToLower(match[0]))
+		}
+	}
+
+	for _, ip := range ipv4Pattern.FindAllString(value, -1) {
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() != nil {
+			iocs.add(&iocs.IPv4, str, "ipv4", "", ip)
+		}
+	}
+
+	for _, match := range ipv6Pattern.FindAllStringIndex(value, -1) {
+		ip := value[match[0]:match[1]]
+		// require three groups and no adjacent word characters so "std::vector" is not an address
+		if isWordChar(value, match[0]-1) || isWordChar(value, match[1]) || len(strings.FieldsFunc(ip, isColon)) < 3 {
+			continue
+		}
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+			iocs.add(&iocs.IPv6, str, "ipv6", "", ip)
+		}
+	}
+
+	for _, path := range windowsPath.FindAllString(value, -1) {
+		iocs.add(&iocs.Paths, str, "path", "windows", path)
+	}
+	for _, match := range unixPath.FindAllStringSubmatch(value, -1) {
+		iocs.add(&iocs.Paths, str, "path", "unix", match[1])
+	}
+
+	for _, key := range registryPattern.FindAllString(value, -1) {
+		iocs.add(&iocs.RegistryKeys, str, "registry_key", "", key)
+	}
+
+	for _, mutex := range mutexPattern.FindAllString(value, -1) {
+		iocs.add(&iocs.Mutexes, str, "mutex", "", mutex)
+	}
+
+	for _, wallet := range btcPattern.FindAllString(value, -1) {
+		if validBase58Check(wallet) {
+			iocs.add(&iocs.Wallets, str, "wallet", "bitcoin", wallet)
+		}
+	}
+	for _, wallet := range bech32Pattern.FindAllString(value, -1) {
+		if validBech32(wallet) {
+			iocs.add(&iocs.Wallets, str, "wallet", "bitcoin", wallet)
+		}
+	}
+	for _, wallet := range ethPattern.FindAllString(value, -1) {
+		iocs.add(&iocs.Wallets, str, "wallet", "ethereum", wallet)
+	}
+	for _, wallet := range xmrPattern.FindAllString(value, -1) {
+		iocs.add(&iocs.Wallets, str, "wallet", "monero", wallet)
+	}
+
+	for _, blob := range base64Pattern.FindAllString(value, -1) {
+		if isBase64Blob(blob) {
+			iocs.add(&iocs.Base64, str, "base64", "", blob)
+		}
+	}
+}
+
+func (iocs *IOCs) add(list *[]IOC, str String, kind string, subkind string, value string) {
+
+	if len(value) == 0 {
+		return
+	}
+	if iocs.seen == nil {
+		iocs.seen = map[string]bool{}
+	}
+
+	key := kind + "\x00" + value
+	if iocs.seen[key] {
+		return
+	}
+	iocs.seen[key] = true
+
+	*list = append(*list, IOC{
+		Value:  value,
+		Offset: str.Offset,
+		Source: str.Type,
+		Kind:   subkind,
+	})
+}
+
+func isColon(c rune) bool {
+	return c == ':'
+}
+
+// isWordChar reports whether the byte at i is a letter, digit or underscore
+func isWordChar(value string, i int) bool {
+	if i < 0 || i >= len(value) {
+		return false
+	}
+	c := value[i]
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// isBase64Blob reports whether blob decodes as base64 and mixes character
+// classes, so long identifiers and words are not reported
+func isBase64Blob(blob string) bool {
+
+	if len(blob)%4 != 0 {
+		return false
+	}
+	if _, err := base64.StdEncoding.DecodeString(blob); err != nil {
+		return false
+	}
+
+	var upper, lower, digit bool
+	for _, c := range blob {
+		switch {
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= '0' && c <= '9':
+			digit = true
+		}
+	}
+
+	return upper && lower && digit
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// validBase58Check verifies the checksum of a base58check encoded address
+func validBase58Check(address string) bool {
+
+	n := new(big.Int)
+	for _, c := range address {
+		i := strings.IndexRune(base58Alphabet, c)
+		if i < 0 {
+			return false
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+
+	decoded := n.Bytes()
+	for _, c := range address {
+		if c != '1' {
+			break
+		}
+		decoded = append([]byte{0}, decoded...)
+	}
+	if len(decoded) != 25 {
+		return false
+	}
+
+	first := sha256.Sum256(decoded[:21])
+	second := sha256.Sum256(first[:])
+
+	return string(second[:4]) == string(decoded[21:])
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// validBech32 verifies the checksum of a bech32 or bech32m address
+func validBech32(address string) bool {
+
+	separator := strings.LastIndex(address, "1")
+	if separator < 1 || separator+7 > len(address) {
+		return false
+	}
+
+	hrp := address[:separator]
+	values := []int{}
+	for _, c := range hrp {
+		values = append(values, int(c)>>5)
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, int(c)&31)
+	}
+	for _, c := range address[separator+1:] {
+		i := strings.IndexRune(bech32Charset, c)
+		if i < 0 {
+			return false
+		}
+		values = append(values, i)
+	}
+
+	generator := []int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	checksum := 1
+	for _, v := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				checksum ^= generator[i]
+			}
+		}
+	}
+
+	return checksum == 1 || checksum == 0x2bc830a3
+}
+
+// validDomain checks the TLD and rejects file names such as "libc.so" whose
+// extension happens to be a country code
+func validDomain(domain string, tld string) bool {
+
+	if !validTLD(tld) || ipv4Pattern.MatchString(domain) {
+		return false
+	}
+	if extensionTLDs[strings.ToLower(tld)] && strings.Count(domain, ".") < 2 {
+		return false
+	}
+
+	return true
+}
+
+var extensionTLDs = map[string]bool{
+	"so": true, "py": true, "sh": true, "pl": true, "rs": true, "cc": true,
+	"ps": true, "md": true, "cs": true, "pm": true, "mk": true, "ml": true,
+}
+
+// validTLD reports whether tld is a country code or a commonly used generic top level domain
+func validTLD(tld string) bool {
+	return tlds[strings.ToLower(tld)]
+}
+
+var tlds = func() map[string]bool {
+	list := `ac ad ae af ag ai al am ao aq ar as at au aw ax az ba bb bd be bf bg bh bi bj bm bn bo br bs bt bw by bz
+ca cc cd cf cg ch ci ck cl cm cn co cr cu cv cw cx cy cz de dj dk dm do dz ec ee eg er es et eu fi fj fk fm fo fr
+ga gd ge gf gg gh gi gl gm gn gp gq gr gs gt gu gw gy hk hm hn hr ht hu id ie il im in io iq ir is it je jm jo jp
+ke kg kh ki km kn kp kr kw ky kz la lb lc li lk lr ls lt lu lv ly ma mc md me mg mh mk ml mm mn mo mp mq mr ms mt
+mu mv mw mx my mz na nc ne nf ng ni nl no np nr nu nz om pa pe pf pg ph pk pl pm pn pr ps pt pw py qa re ro rs ru
+rw sa sb sc sd se sg sh si sk sl sm sn so sr ss st su sv sx sy sz tc td tf tg th tj tk tl tm tn to tr tt tv tw tz
+ua ug uk us uy uz va vc ve vg vi vn vu wf ws ye yt za zm zw
+com net org info biz gov edu mil int arpa name pro mobi asia tel travel xxx aero coop jobs museum cat post
+app dev io ai xyz top online site club shop store tech space website live life world today news blog cloud
+email link click help host fun icu buzz work vip win bid loan men party review stream trade date download racing
+cricket science accountant faith gdn kim ooo best cyou rest bar monster uno lol wang ink ltd group global
+onion bit`
+
+	tlds := map[string]bool{}
+	for _, tld := range strings.Fields(list) {
+		tlds[tld] = true
+	}
+	return tlds
+}()
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/LiamHellend/malscan-plugin-floss/utils"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
@@ -27,13 +29,52 @@ var (
 
 // ResultsData - holds scan results
 type ResultsData struct {
-	Strings []string `json:"strings,omitempty" structs:"ascii_strings"`
-	Error   string   `json:"error" structs:"error"`
+	Strings    []String        `json:"strings,omitempty" structs:"strings"`
+	Truncated  bool            `json:"truncated" structs:"truncated"`
+	Decoded    []DecodedString `json:"decoded_strings,omitempty" structs:"decoded_strings"`
+	Stack      []StackString   `json:"stack_strings,omitempty" structs:"stack_strings"`
+	Tight      []StackString   `json:"tight_strings,omitempty" structs:"tight_strings"`
+	IOCs       IOCs            `json:"iocs" structs:"iocs"`
+	Error      string          `json:"error" structs:"error"`
+	ErrorCode  string          `json:"error_code,omitempty" structs:"error_code"`
+	ExitReason string          `json:"exit_reason,omitempty" structs:"exit_reason"`
 }
 
-type decodedStrings struct {
-	Location string   `json:"location" structs:"location"`
-	Strings  []string `json:"strings" structs:"strings"`
+// String - holds an extracted string with where it was found
+type String struct {
+	String   string  `json:"string" structs:"string"`
+	Offset   string  `json:"offset,omitempty" structs:"offset"`
+	Encoding string  `json:"encoding" structs:"encoding"`
+	Type     string  `json:"type" structs:"type"`
+	Score    float64 `json:"score,omitempty" structs:"score"`
+}
+
+// Options - holds the string extraction options
+type Options struct {
+	All       bool
+	MinLength int
+	MaxCount  int
+	Encoding  string
+	Rank      int
+}
+
+// DecodedString - holds a string FLOSS recovered by emulating a decoding routine
+type DecodedString struct {
+	String          string `json:"string" structs:"string"`
+	Encoding        string `json:"encoding" structs:"encoding"`
+	Address         string `json:"address" structs:"address"`
+	AddressType     string `json:"address_type" structs:"address_type"`
+	DecodedAt       string `json:"decoded_at" structs:"decoded_at"`
+	DecodingRoutine string `json:"decoding_routine" structs:"decoding_routine"`
+}
+
+// StackString - holds a stack or tight string and the function that builds it
+type StackString struct {
+	String         string `json:"string" structs:"string"`
+	Encoding       string `json:"encoding" structs:"encoding"`
+	Function       string `json:"function" structs:"function"`
+	ProgramCounter string `json:"program_counter" structs:"program_counter"`
+	FrameOffset    int64  `json:"frame_offset" structs:"frame_offset"`
 }
 
 // floss - holds full set of results
@@ -41,64 +82,129 @@ type floss struct {
 	Results ResultsData `json:"analysis" structs:"analysis"`
 }
 
-// scanFile scans file with all floss rules in the rules folder
-func scanFile(ctx context.Context, all bool) floss {
+// scanFile extracts strings from the file, options.All enables decoded, stack and tight strings
+func scanFile(ctx context.Context, options Options) floss {
 
 	flossResults := floss{}
 
-	output, err := exec.CommandContext(ctx, "/opt/floss", "--no-decoded-strings", "--no-stack-strings", "--minimum-length=8", "-g", path).Output()
-	if ctx.Err() == context.DeadlineExceeded {
-		return floss{ResultsData{Error: "timeout"}}
+	args := []string{"-q", "-j", fmt.Sprintf("--minimum-length=%d", options.MinLength), path}
+	if !options.All {
+		args = append(args, "--only", "static")
 	}
-	if err != nil {
-		return floss{ResultsData{Error: "cmd failed: /opt/floss--no-decoded-strings --no-stack-strings --minimum-length=6 -g " + path}}
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "/opt/floss", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil || ctx.Err() == context.DeadlineExceeded {
+		if err == nil {
+			err = ctx.Err()
+		}
+		log.Debug(stderr.String())
+
+		code, reason := classifyFailure(ctx, err, stderr.String())
+		return floss{ResultsData{
+			Error:      fmt.Sprintf("cmd failed: /opt/floss %s: %s", strings.Join(args, " "), err),
+			ErrorCode:  code,
+			ExitReason: reason,
+		}}
 	}
 
-	flossResults.Results = parseFlossOutput(string(output), all)
+	flossResults.Results = parseFlossOutput(output, options)
 
 	return flossResults
 }
 
-func parseFlossOutput(flossOutput string, all bool) ResultsData {
+func parseFlossOutput(flossOutput []byte, options Options) ResultsData {
 
-	/*log.WithFields(log.Fields{
-		"plugin":   name,
-		"category": category,
-		"path":     path,
-	}).Debug("FLOSS Output: ", flossOutput)
-	*/
-	keepLines := []string{}
 	results := ResultsData{Error: "nil"}
 
-	lines := strings.Split(flossOutput, "\n")
-	// remove empty lines
-	for _, line := range lines {
-		if len(strings.TrimSpace(line)) != 0 {
-			keepLines = append(keepLines, strings.TrimSpace(line))
+	var report flossReport
+
+	err := json.Unmarshal(flossOutput, &report)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while unmarshaling floss output"))
+		results.Error = err.Error()
+		results.ErrorCode = errJSONDecode
+		return results
+	}
+
+	for _, str := range report.Strings.Static {
+		results.addString(str.String, hexAddress(str.Offset), str.Encoding, "static", options)
+	}
+
+	if options.All {
+		for _, str := range report.Strings.Decoded {
+			if keepString(str.String, str.Encoding, options) {
+				results.Decoded = append(results.Decoded, str.decodedString())
+			}
+			results.addString(str.String, hexAddress(str.Address), str.Encoding, "decoded", options)
+		}
+		for _, str := range report.Strings.Stack {
+			if keepString(str.String, str.Encoding, options) {
+				results.Stack = append(results.Stack, str.stackString())
+			}
+			results.addString(str.String, "", str.Encoding, "stack", options)
+		}
+		for _, str := range report.Strings.Tight {
+			if keepString(str.String, str.Encoding, options) {
+				results.Tight = append(results.Tight, str.stackString())
+			}
+			results.addString(str.String, "", str.Encoding, "tight", options)
+		}
+	}
+
+	if options.Rank > 0 {
+		count := options.Rank
+		if options.MaxCount > 0 && options.MaxCount < count {
+			count = options.MaxCount
 		}
+		var cut bool
+		results.Strings, cut = rankStrings(results.Strings, count)
+		results.Truncated = results.Truncated || cut
 	}
 
-	// build results data
-	for i := 0; i < len(keepLines); i++ {
+	return results
+}
 
-		results.Strings = utils.RemoveDuplicates(getStrings(keepLines))
+// addString extracts the IOCs in the string and appends it if it passes the filters and the max count is not reached
+func (r *ResultsData) addString(str string, offset string, encoding string, kind string, options Options) {
 
+	extracted := String{
+		String:   str,
+		Offset:   offset,
+		Encoding: encoding,
+		Type:     kind,
 	}
 
-	log.Debug(results)
+	// IOCs are extracted from every string, not only the ones that are reported
+	r.IOCs.extract(extracted)
 
-	return results
+	if !keepString(str, encoding, options) {
+		return
+	}
+	// ranking needs every string, the count is applied after scoring
+	if options.Rank == 0 && options.MaxCount > 0 && len(r.Strings) >= options.MaxCount {
+		r.Truncated = true
+		return
+	}
+
+	r.Strings = append(r.Strings, extracted)
 }
 
-func getStrings(strArray []string) []string {
-	asciiStrings := []string{}
-	for _, str := range strArray {
-		if !strings.Contains(str, "FLOSS") {
-			asciiStrings = append(asciiStrings, str)
-		}
+// keepString applies the minimum length and encoding filters
+func keepString(str string, encoding string, options Options) bool {
 
+	if len([]rune(str)) < options.MinLength {
+		return false
+	}
+	if len(options.Encoding) != 0 && !strings.EqualFold(options.Encoding, encoding) {
+		return false
 	}
-	return asciiStrings
+
+	return true
 }
 
 func main() {
@@ -120,6 +226,32 @@ func main() {
 			Usage:  "malcan plugin timeout (in seconds)",
 			EnvVar: "MALSCAN_TIMEOUT",
 		},
+		cli.BoolFlag{
+			Name:   "all, a",
+			Usage:  "also extract decoded, stack and tight strings",
+			EnvVar: "MALSCAN_FLOSS_ALL",
+		},
+		cli.IntFlag{
+			Name:   "min-length, n",
+			Value:  8,
+			Usage:  "minimum string length",
+			EnvVar: "MALSCAN_FLOSS_MIN_LENGTH",
+		},
+		cli.IntFlag{
+			Name:   "max-count",
+			Usage:  "maximum number of strings to report, 0 for all",
+			EnvVar: "MALSCAN_FLOSS_MAX_COUNT",
+		},
+		cli.StringFlag{
+			Name:   "encoding",
+			Usage:  "only report strings with this encoding (ASCII or UTF-16LE)",
+			EnvVar: "MALSCAN_FLOSS_ENCODING",
+		},
+		cli.IntFlag{
+			Name:   "rank",
+			Usage:  "only report the N most relevant strings, ranked by score",
+			EnvVar: "MALSCAN_FLOSS_RANK",
+		},
 	}
 	app.Action = func(c *cli.Context) error {
 
@@ -133,7 +265,13 @@ func main() {
 		if c.Args().Present() {
 			path, _ = filepath.Abs(c.Args().First())
 
-			floss := scanFile(ctx, false)
+			floss := scanFile(ctx, Options{
+				All:       c.Bool("all"),
+				MinLength: c.Int("min-length"),
+				MaxCount:  c.Int("max-count"),
+				Encoding:  c.String("encoding"),
+				Rank:      c.Int("rank"),
+			})
 
 			//Convert clamav results to json
 			flossJSON, _ := json.Marshal(floss)
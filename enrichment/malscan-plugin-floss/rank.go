@@ -0,0 +1,220 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+var (
+	formatPattern = regexp.MustCompile(`%[-+ #0]*\d*(?:\.\d+)?(?:l|ll|h|I64)?[sdiuxXpcSfgn]|\{\d+\}`)
+	wordPattern   = regexp.MustCompile(`[A-Za-z]{3,}`)
+)
+
+// rankStrings scores every string and keeps the count highest scoring ones
+func rankStrings(strs []String, count int) ([]String, bool) {
+
+	for i := range strs {
+		strs[i].Score = scoreString(strs[i].String)
+	}
+
+	sort.SliceStable(strs, func(i, j int) bool { return strs[i].Score > strs[j].Score })
+
+	if len(strs) > count {
+		return strs[:count], true
+	}
+	return strs, false
+}
+
+// scoreString rates how likely a string is to interest an analyst, rewarding
+// indicators, API names, words and format strings and penalising library noise
+// and random looking data
+func scoreString(str string) float64 {
+
+	trimmed := strings.TrimSpace(str)
+	if len(trimmed) == 0 {
+		return 0
+	}
+
+	for _, noise := range noiseStrings {
+		if strings.Contains(trimmed, noise) {
+			return -10
+		}
+	}
+
+	score := math.Min(math.Log2(float64(len(trimmed))), 6)
+
+	// indicators are the strings analysts look for first
+	switch {
+	case urlPattern.MatchString(trimmed), ipv4Pattern.MatchString(trimmed):
+		score += 10
+	case registryPattern.MatchString(trimmed), mutexPattern.MatchString(trimmed):
+		score += 8
+	case windowsPath.MatchString(trimmed), unixPath.MatchString(trimmed):
+		score += 6
+	}
+
+	if interestingAPIs[trimmed] {
+		score += 8
+	}
+
+	if formatPattern.MatchString(trimmed) {
+		score += 3
+	}
+
+	words := 0
+	for _, word := range wordPattern.FindAllString(trimmed, -1) {
+		lower := strings.ToLower(word)
+		if keywords[lower] {
+			score += 4
+		}
+		if dictionary[lower] || keywords[lower] {
+			words++
+		}
+	}
+	score += math.Min(float64(words), 5)
+
+	// English text sits around 3.5-4.5 bits per character, runs of one
+	// character are padding and anything much higher is packed data
+	entropy := shannonEntropy(trimmed)
+	switch {
+	case entropy < 2:
+		score -= 4
+	case entropy > 5:
+		score -= 2
+	}
+
+	// strings made mostly of symbols are usually code or data misread as text
+	score -= 8 * symbolRatio(trimmed)
+
+	return math.Round(score*100) / 100
+}
+
+// shannonEntropy returns the entropy of str in bits per character
+func shannonEntropy(str string) float64 {
+
+	counts := map[rune]float64{}
+	total := 0.0
+	for _, c := range str {
+		counts[c]++
+		total++
+	}
+
+	entropy := 0.0
+	for _, count := range counts {
+		p := count / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// symbolRatio returns the share of characters that are neither letters, digits nor spaces
+func symbolRatio(str string) float64 {
+
+	symbols, total := 0.0, 0.0
+	for _, c := range str {
+		total++
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && !unicode.IsSpace(c) {
+			symbols++
+		}
+	}
+
+	return symbols / total
+}
+
+// noiseStrings are fragments of strings compilers and runtime libraries add to every binary
+var noiseStrings = []string{
+	"Microsoft Visual C++ Runtime Library",
+	"Runtime Error!",
+	"R6002", "R6008", "R6009", "R6016", "R6017", "R6018", "R6019", "R6024", "R6025", "R6026", "R6027", "R6028", "R6030", "R6031", "R6032", "R6033", "R6034",
+	"This program cannot be run in DOS mode",
+	"!This program cannot be run",
+	"bad allocation",
+	"bad exception",
+	"Unknown exception",
+	"string too long",
+	"invalid string position",
+	"vector<T> too long",
+	"__CxxFrameHandler",
+	"_CorExeMain",
+	"_except_handler",
+	"__security_cookie",
+	"api-ms-win-",
+	"ext-ms-win-",
+	"GCC: (",
+	"GLIBC_",
+	"GLIBCXX_",
+	"CXXABI_",
+	".gnu.version",
+	"_ITM_deregisterTMCloneTable",
+	"__gmon_start__",
+	"go.buildid",
+	"runtime.",
+	"abcdefghijklmnopqrstuvwxyz",
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"0123456789abcdef",
+	"0123456789ABCDEF",
+	"mscoree.dll",
+	"CorExitProcess",
+	"FlsAlloc",
+	"FlsFree",
+	"FlsGetValue",
+	"FlsSetValue",
+	"InitializeCriticalSectionEx",
+	"<assembly xmlns",
+	"requestedExecutionLevel",
+	"urn:schemas-microsoft-com",
+}
+
+// interestingAPIs are Windows APIs commonly used for injection, persistence,
+// evasion and network access
+var interestingAPIs = toSet(`
+VirtualAlloc VirtualAllocEx VirtualProtect VirtualProtectEx WriteProcessMemory ReadProcessMemory
+CreateRemoteThread CreateRemoteThreadEx NtCreateThreadEx RtlCreateUserThread QueueUserAPC NtQueueApcThread
+SetThreadContext GetThreadContext ResumeThread SuspendThread NtUnmapViewOfSection ZwUnmapViewOfSection
+OpenProcess CreateProcessA CreateProcessW CreateProcessAsUserA CreateProcessAsUserW ShellExecuteA ShellExecuteW
+ShellExecuteExA ShellExecuteExW WinExec LoadLibraryA LoadLibraryW LoadLibraryExA LoadLibraryExW GetProcAddress
+SetWindowsHookExA SetWindowsHookExW GetAsyncKeyState GetKeyState GetForegroundWindow
+RegCreateKeyExA RegCreateKeyExW RegSetValueExA RegSetValueExW RegOpenKeyExA RegOpenKeyExW
+CreateServiceA CreateServiceW StartServiceA StartServiceW OpenSCManagerA OpenSCManagerW
+IsDebuggerPresent CheckRemoteDebuggerPresent NtQueryInformationProcess OutputDebugStringA
+CryptEncrypt CryptDecrypt CryptAcquireContextA CryptAcquireContextW CryptGenKey CryptImportKey BCryptEncrypt BCryptDecrypt
+InternetOpenA InternetOpenW InternetOpenUrlA InternetOpenUrlW InternetConnectA InternetConnectW
+HttpOpenRequestA HttpOpenRequestW HttpSendRequestA HttpSendRequestW InternetReadFile URLDownloadToFileA URLDownloadToFileW
+WinHttpOpen WinHttpConnect WinHttpSendRequest WSAStartup socket connect send recv gethostbyname getaddrinfo
+AdjustTokenPrivileges LookupPrivilegeValueA LookupPrivilegeValueW OpenProcessToken ImpersonateLoggedOnUser
+CreateToolhelp32Snapshot Process32First Process32Next Process32FirstW Process32NextW
+MiniDumpWriteDump DeleteFileA DeleteFileW MoveFileExA MoveFileExW
+`)
+
+// keywords are words that usually indicate malicious or configuration strings
+var keywords = toSet(`
+password passwd pwd login admin root token secret credential credentials cookie session
+shell cmd powershell bash exec execute inject injection payload dropper loader beacon implant
+download upload exfil keylog keylogger screenshot webcam clipboard bitcoin wallet ransom encrypt
+decrypt bot botnet proxy socks tunnel backdoor persistence startup autorun schtasks vssadmin
+bcdedit wevtutil mimikatz sandbox vmware virtualbox vbox qemu debugger wireshark procmon
+`)
+
+// dictionary is a small set of common English words used to spot readable text
+var dictionary = toSet(`
+the and for are but not you all any can had her was one our out day get has him his how man new now old see two way
+who boy did its let put say she too use that with have this will your from they know want been good much some time very
+when come here just like long make many more only over such take than them well were what file error failed unable
+cannot could should would please enter value name path user data system service process thread memory window version
+open close read write create delete copy move send receive start stop run load save update check server client request
+response connect connection network host port address local remote registry key default settings config configuration
+invalid success warning information message text string number count size length buffer directory folder program
+application install uninstall access denied found exists missing internal external public private object class method
+`)
+
+func toSet(list string) map[string]bool {
+	set := map[string]bool{}
+	for _, item := range strings.Fields(list) {
+		set[item] = true
+	}
+	return set
+}
@@ -0,0 +1,55 @@
+package main
+
+import "fmt"
+
+// flossReport is the part of FLOSS's json output (-j) the plugin reads
+type flossReport struct {
+	Metadata struct {
+		Version string `json:"version"`
+	} `json:"metadata"`
+	Strings struct {
+		Static  []flossString `json:"static_strings"`
+		Stack   []flossString `json:"stack_strings"`
+		Tight   []flossString `json:"tight_strings"`
+		Decoded []flossString `json:"decoded_strings"`
+	} `json:"strings"`
+}
+
+// flossString covers the fields of every FLOSS string type, each type only sets some of them
+type flossString struct {
+	String          string `json:"string"`
+	Encoding        string `json:"encoding"`
+	Offset          uint64 `json:"offset"`
+	Function        uint64 `json:"function"`
+	ProgramCounter  uint64 `json:"program_counter"`
+	FrameOffset     int64  `json:"frame_offset"`
+	Address         uint64 `json:"address"`
+	AddressType     string `json:"address_type"`
+	DecodedAt       uint64 `json:"decoded_at"`
+	DecodingRoutine uint64 `json:"decoding_routine"`
+}
+
+func (s flossString) stackString() StackString {
+	return StackString{
+		String:         s.String,
+		Encoding:       s.Encoding,
+		Function:       hexAddress(s.Function),
+		ProgramCounter: hexAddress(s.ProgramCounter),
+		FrameOffset:    s.FrameOffset,
+	}
+}
+
+func (s flossString) decodedString() DecodedString {
+	return DecodedString{
+		String:          s.String,
+		Encoding:        s.Encoding,
+		Address:         hexAddress(s.Address),
+		AddressType:     s.AddressType,
+		DecodedAt:       hexAddress(s.DecodedAt),
+		DecodingRoutine: hexAddress(s.DecodingRoutine),
+	}
+}
+
+func hexAddress(address uint64) string {
+	return fmt.Sprintf("0x%x", address)
+}
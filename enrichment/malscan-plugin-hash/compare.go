@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Comparison json object (this is what the compare command outputs)
+type Comparison struct {
+	Results CompareData `json:"comparison" structs:"comparison"`
+}
+
+// CompareData json object
+type CompareData struct {
+	Sample  string
+	SSDeep  string
+	TLSH    string
+	Matches []Match
+	Error   string `json:"error" structs:"error"`
+}
+
+// Match json object, a missing hash on either side scores 0 for ssdeep and -1 for tlsh
+type Match struct {
+	Target       string `json:"target" structs:"target"`
+	SSDeep       string `json:"ssdeep,omitempty" structs:"ssdeep"`
+	TLSH         string `json:"tlsh,omitempty" structs:"tlsh"`
+	SSDeepScore  int    `json:"ssdeep_score" structs:"ssdeep_score"`
+	TLSHDistance int    `json:"tlsh_distance" structs:"tlsh_distance"`
+}
+
+// compareFiles hashes both samples and compares the second one to the first
+func compareFiles(first string, second string) Comparison {
+
+	first, _ = filepath.Abs(first)
+	second, _ = filepath.Abs(second)
+
+	sample := hashFile(first)
+	if sample.Error != "nil" {
+		return Comparison{Results: CompareData{Sample: first, Error: sample.Error}}
+	}
+	other := hashFile(second)
+	if other.Error != "nil" {
+		return Comparison{Results: CompareData{Sample: first, Error: other.Error}}
+	}
+
+	return Comparison{Results: CompareData{
+		Sample:  first,
+		SSDeep:  sample.SSDeep,
+		TLSH:    sample.TLSH,
+		Matches: []Match{match(sample, second, other.SSDeep, other.TLSH)},
+		Error:   "nil",
+	}}
+}
+
+// compareList hashes the sample and reports the hash list entries scoring at least
+// ssdeepThreshold or within tlshThreshold of it, best matches first
+func compareList(file string, list string, ssdeepThreshold int, tlshThreshold int) Comparison {
+
+	file, _ = filepath.Abs(file)
+
+	sample := hashFile(file)
+	if sample.Error != "nil" {
+		return Comparison{Results: CompareData{Sample: file, Error: sample.Error}}
+	}
+
+	results := CompareData{Sample: file, SSDeep: sample.SSDeep, TLSH: sample.TLSH, Matches: []Match{}, Error: "nil"}
+
+	entries, err := readHashList(list)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading hash list"))
+		results.Error = err.Error()
+		return Comparison{Results: results}
+	}
+
+	for _, entry := range entries {
+		m := match(sample, entry.Target, entry.SSDeep, entry.TLSH)
+		if (len(m.SSDeep) != 0 && m.SSDeepScore >= ssdeepThreshold) || (m.TLSHDistance >= 0 && m.TLSHDistance <= tlshThreshold) {
+			results.Matches = append(results.Matches, m)
+		}
+	}
+
+	sort.SliceStable(results.Matches, func(i, j int) bool {
+		if results.Matches[i].SSDeepScore != results.Matches[j].SSDeepScore {
+			return results.Matches[i].SSDeepScore > results.Matches[j].SSDeepScore
+		}
+		return tlshRank(results.Matches[i]) < tlshRank(results.Matches[j])
+	})
+
+	return Comparison{Results: results}
+}
+
+// match compares the sample's fuzzy hashes to a target's
+func match(sample ResultsData, target string, ssdeep string, tlsh string) Match {
+
+	m := Match{Target: target, SSDeep: ssdeep, TLSH: tlsh, TLSHDistance: -1}
+
+	if len(sample.SSDeep) != 0 && len(ssdeep) != 0 {
+		score, err := compareSSDeep(sample.SSDeep, ssdeep)
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while comparing ssdeep hash of %s", target))
+		}
+		m.SSDeepScore = score
+	}
+
+	if len(sample.TLSH) != 0 && len(tlsh) != 0 {
+		distance, err := compareTLSH(sample.TLSH, tlsh)
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while comparing tlsh hash of %s", target))
+		} else {
+			m.TLSHDistance = distance
+		}
+	}
+
+	return m
+}
+
+// tlshRank orders missing tlsh distances after every real one
+func tlshRank(m Match) int {
+	if m.TLSHDistance < 0 {
+		return int(^uint(0) >> 1)
+	}
+	return m.TLSHDistance
+}
+
+// readHashList reads a csv hash list, every field is recognised as an ssdeep hash,
+// a tlsh hash or the entry's name, so ssdeep's own output can be used directly
+func readHashList(list string) ([]Match, error) {
+
+	f, err := os.Open(list)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	var entries []Match
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid hash list")
+		}
+
+		var entry Match
+		for _, field := range record {
+			field = strings.TrimSpace(field)
+			switch {
+			case len(field) == 0:
+			case len(entry.SSDeep) == 0 && isSSDeep(field):
+				entry.SSDeep = field
+			case len(entry.TLSH) == 0 && isTLSH(field):
+				entry.TLSH = field
+			case len(entry.Target) == 0:
+				entry.Target = field
+			}
+		}
+
+		// headers and other lines without a hash are skipped
+		if len(entry.SSDeep) == 0 && len(entry.TLSH) == 0 {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func isSSDeep(hash string) bool {
+	_, _, _, err := parseSSDeep(hash)
+	return err == nil
+}
+
+func isTLSH(hash string) bool {
+	_, err := parseTLSH(hash)
+	return err == nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// randomInput returns the first n bytes math/rand produces when seeded with 1
+func randomInput(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// TestSSDeepKnownAnswers checks the digests of the blobs github.com/glaslos/ssdeep
+// publishes ssdeep output for, read one after the other from math/rand seeded with 1
+func TestSSDeepKnownAnswers(t *testing.T) {
+
+	tests := []struct {
+		size int
+		hash string
+	}{
+		{4097, "96:yNDH/iNQaSXRLmOSxu1aQP4iWgC8JbkiA5Ix:yNLaNQhSxEgVYkiA5Ix"},
+		{45056, "768:mlHmRZnCRFRwSuK/UiwY37TMbsDEsb1Jqi6dcXoWpKXIUxpQDOAvWpPK:mqhCJwjmJD31DzbDwd+oGo9AvOi"},
+		{86016, "1536:Jdr3F6yZG0agLg/b6G6REjI+WUhWDKRSpzKjSUT4plmjvX6ex7RwdsHIGV:PrVbZG0BuuGzc+WcdRilmbPx7RwGV"},
+		{126976, "3072:pwP2ZmVLsvDAyshOZIzFkGxIE++3ysSsZCj3JwAjpn:ps2/DAyKIaRyE++RSsUj3JwaJ"},
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for _, test := range tests {
+		data := make([]byte, test.size)
+		r.Read(data)
+
+		s := newSSDeep(int64(len(data)))
+		s.Write(data)
+		if sum := s.Sum(); sum != test.hash {
+			t.Errorf("ssdeep of %d bytes = %s, want %s", test.size, sum, test.hash)
+		}
+	}
+}
+
+// TestSSDeepCompare checks the ssdeep -d scores published with these digests
+func TestSSDeepCompare(t *testing.T) {
+
+	tests := []struct {
+		a, b  string
+		score int
+	}{
+		{
+			"192:MUPMinqP6+wNQ7Q40L/iB3n2rIBrP0GZKF4jsef+0FVQLSwbLbj41iH8nFVYv980:x0CllivQiFmt",
+			"192:MUPMinqP6+wNQ7Q40L/iB3n2rIBrP0GZKF4jsef+0FVQLSwbLbj41iH8nFVYv980:x0CllivQiFmt",
+			100,
+		},
+		{
+			"192:MUPMinqP6+wNQ7Q40L/iB3n2rIBrP0GZKF4jsef+0FVQLSwbLbj41iH8nFVYv980:x0CllivQiFmt",
+			"192:JkjRcePWsNVQza3ntZStn5VfsoXMhRD9+xJMinqF6+wNQ7Q40L/i737rPVt:JkjlQyIrx+kll2",
+			35,
+		},
+		{
+			"196608:pDSC8olnoL1v/uawvbQD7XlZUFYzYyMb615NktYHF7dREN/JNnQrmhnUPI+/n2Yr:5DHoJXv7XOq7Mb2TwYHXREN/3QrmktPd",
+			"196608:7DSC8olnoL1v/uawvbQD7XlZUFYzYyMb615NktYHF7dREN/JNnQrmhnUPI+/n2Y7:3DHoJXv7XOq7Mb2TwYHXREN/3QrmktPt",
+			97,
+		},
+		{
+			"24:YDVLfsT1ds/1H9Wpgq7n4XMijV6h4Z3QCw4qat:YD51H9CiMuV6uACwVat",
+			"24:YDVLfyvDj+C+opg8DV0Mdle6hPZ3QCw4qat:YDMvDj+C+kBOM+6HACwVat",
+			54,
+		},
+	}
+
+	for _, test := range tests {
+		score, err := compareSSDeep(test.a, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score != test.score {
+			t.Errorf("compareSSDeep(%s, %s) = %d, want %d", test.a, test.b, score, test.score)
+		}
+	}
+}
+
+// tlshHashes are the digests of randomInput around the 656 and 3199 byte steps of
+// the length capture. They were computed from the algorithm of the reference
+// tlsh_impl.cpp rather than with the tlsh binary, rerun tlsh -f on the same
+// inputs to refresh them.
+var tlshHashes = map[int]string{
+	600:    "T12CF0B7A1A735801CF3F62CA7BCE07B302E21804B8921A8523348F67DD4B0953938573C",
+	656:    "T1B0F088B15739801CE7F61C537CE17B302D5050478421A5523358F57D94B05675385728",
+	657:    "T1C70188B15739801DE7F61C537CE17B302D5450478421A5523358F57D94B05675385728",
+	3000:   "T1C1514CF52B7DF10FFDD81657B6A452288094840790ABA64723EDDD7E9E5CDB2830823A",
+	3199:   "T1CE615CB62F7DF10FECD82657B7A453288094840790AA768722EDDD3F9E1CDB24309369",
+	3200:   "T1A7615CB62F7DF10FECD82657B7A453288094840790AA768722EDDD3F9E1CDB24309369",
+	5000:   "T156A19F343FA8F00FA99026A776AD552CD484854391BF784223E8C93FDF1EEB143093A2",
+	100000: "T198A3126028FC0F7F9C122588F45D569A92D4310F74FDB82361E843B6DB4AFA885099F9",
+}
+
+func TestTLSHKnownAnswers(t *testing.T) {
+
+	for size, hash := range tlshHashes {
+		h := newTLSH()
+		h.Write(randomInput(size))
+		if sum := h.Sum(); sum != hash {
+			t.Errorf("tlsh of %d bytes = %s, want %s", size, sum, hash)
+		}
+	}
+
+	h := newTLSH()
+	h.Write(randomInput(tlshMinDataLength - 1))
+	if sum := h.Sum(); len(sum) != 0 {
+		t.Errorf("tlsh of %d bytes = %s, want no hash", tlshMinDataLength-1, sum)
+	}
+}
+
+// TestTLSHLength checks the length byte at the steps of the reference l_capturing
+func TestTLSHLength(t *testing.T) {
+
+	tests := []struct {
+		length uint64
+		value  byte
+	}{
+		{437, 14},
+		{438, 15},
+		{656, 15},
+		{657, 16},
+		{3171, 21},
+		{3172, 22},
+		{3199, 22},
+		{3200, 22},
+		{3475, 22},
+		{3476, 23},
+		{795081, 79},
+		{795082, 80},
+		{962048, 81},
+		{962049, 82},
+	}
+
+	for _, test := range tests {
+		if value := tlshLength(test.length); value != test.value {
+			t.Errorf("tlshLength(%d) = %d, want %d", test.length, value, test.value)
+		}
+	}
+}
+
+// TestTLSHCompare checks the distances of the reference totalDiff between the
+// digests above, length difference included
+func TestTLSHCompare(t *testing.T) {
+
+	tests := []struct {
+		a, b     int
+		distance int
+	}{
+		{656, 656, 0},
+		{656, 657, 4},
+		{3199, 3200, 1},
+		{600, 5000, 397},
+		{3000, 100000, 689},
+	}
+
+	for _, test := range tests {
+		distance, err := compareTLSH(tlshHashes[test.a], tlshHashes[test.b])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if distance != test.distance {
+			t.Errorf("distance between %d and %d bytes = %d, want %d", test.a, test.b, distance, test.distance)
+		}
+	}
+}
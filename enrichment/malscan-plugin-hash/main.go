@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-hash/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "hash"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// Hash json object (this is what gets output)
+type Hash struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Size   int64
+	MD5    string
+	SHA1   string
+	SHA256 string
+	SHA512 string
+	SSDeep string
+	TLSH   string
+	Error  string `json:"error" structs:"error"`
+}
+
+// AvScan hashes the file, giving up after timeout seconds
+func AvScan(timeout int) Hash {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		done <- hashFile(path)
+	}()
+
+	select {
+	case results := <-done:
+		return Hash{Results: results}
+	case <-ctx.Done():
+		return Hash{Results: ResultsData{Error: fmt.Sprintf("hashing of %s timed out", path)}}
+	}
+}
+
+// hashFile computes every hash in a single pass over the file
+func hashFile(file string) ResultsData {
+
+	f, err := os.Open(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while opening file"))
+		return ResultsData{Error: err.Error()}
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file size"))
+		return ResultsData{Error: err.Error()}
+	}
+
+	md5Hash, sha1Hash, sha256Hash, sha512Hash := md5.New(), sha1.New(), sha256.New(), sha512.New()
+	ssdeep := newSSDeep(info.Size())
+	tlsh := newTLSH()
+
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha1Hash, sha256Hash, sha512Hash, ssdeep, tlsh), f)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return ResultsData{Error: err.Error()}
+	}
+
+	return ResultsData{
+		Size:   size,
+		MD5:    hex.EncodeToString(md5Hash.Sum(nil)),
+		SHA1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		SHA512: hex.EncodeToString(sha512Hash.Sum(nil)),
+		SSDeep: ssdeep.Sum(),
+		TLSH:   tlsh.Sum(),
+		Error:  "nil",
+	}
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "Hash"
+	app.Usage = "Malscan hash plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:      "compare",
+			Aliases:   []string{"c"},
+			Usage:     "Compare the fuzzy hashes of two samples, or of a sample against a hash list",
+			ArgsUsage: "SAMPLE [SAMPLE]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:   "list, l",
+					Usage:  "csv hash list to compare against, as written by ssdeep or with tlsh hashes",
+					EnvVar: "MALSCAN_HASH_LIST",
+				},
+				cli.IntFlag{
+					Name:  "ssdeep-threshold",
+					Value: 1,
+					Usage: "lowest ssdeep score reported as a hash list match",
+				},
+				cli.IntFlag{
+					Name:  "tlsh-threshold",
+					Value: 100,
+					Usage: "highest tlsh distance reported as a hash list match",
+				},
+			},
+			Action: func(c *cli.Context) error {
+
+				if c.GlobalBool("debug") {
+					log.SetLevel(log.DebugLevel)
+				}
+
+				var comparison Comparison
+				switch {
+				case c.NArg() == 2 && len(c.String("list")) == 0:
+					comparison = compareFiles(c.Args().Get(0), c.Args().Get(1))
+				case c.NArg() == 1 && len(c.String("list")) != 0:
+					comparison = compareList(c.Args().First(), c.String("list"), c.Int("ssdeep-threshold"), c.Int("tlsh-threshold"))
+				default:
+					comparison = Comparison{Results: CompareData{Error: "compare needs two samples, or one sample and --list"}}
+				}
+
+				comparisonJSON, _ := json.Marshal(comparison)
+
+				fmt.Println(string(comparisonJSON))
+
+				return nil
+			},
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			hash := AvScan(c.Int("timeout"))
+
+			// convert to JSON
+			hashJSON, _ := json.Marshal(hash)
+
+			fmt.Println(string(hashJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
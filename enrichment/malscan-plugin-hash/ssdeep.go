@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ssdeep (context triggered piecewise hashing), following the reference
+// implementation's streaming engine so the file is only read once
+const (
+	ssdeepRollingWindow = 7
+	ssdeepMinBlockSize  = 3
+	ssdeepHashPrime     = 0x01000193
+	ssdeepHashInit      = 0x28021967
+	ssdeepSpamSumLength = 64
+	ssdeepBlockHashes   = 31
+	ssdeepBase64        = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+type ssdeepBlockHash struct {
+	h, halfh   uint32
+	digest     [ssdeepSpamSumLength]byte
+	halfdigest byte
+	dlen       int
+}
+
+type ssdeepRoll struct {
+	window     [ssdeepRollingWindow]uint32
+	h1, h2, h3 uint32
+	n          uint32
+}
+
+func (r *ssdeepRoll) hash(c byte) {
+	r.h2 -= r.h1
+	r.h2 += ssdeepRollingWindow * uint32(c)
+	r.h1 += uint32(c)
+	r.h1 -= r.window[r.n]
+	r.window[r.n] = uint32(c)
+	r.n++
+	if r.n == ssdeepRollingWindow {
+		r.n = 0
+	}
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+func (r *ssdeepRoll) sum() uint32 {
+	return r.h1 + r.h2 + r.h3
+}
+
+// ssdeepState is an io.Writer computing the ssdeep hash of everything written to it,
+// size is the expected input length which picks the block size
+type ssdeepState struct {
+	bhstart, bhend int
+	bh             [ssdeepBlockHashes]ssdeepBlockHash
+	size           uint64
+	written        uint64
+	roll           ssdeepRoll
+}
+
+func newSSDeep(size int64) *ssdeepState {
+	s := &ssdeepState{bhend: 1, size: uint64(size)}
+	s.bh[0].h = ssdeepHashInit
+	s.bh[0].halfh = ssdeepHashInit
+	return s
+}
+
+func ssdeepBlockSize(i int) uint64 {
+	return ssdeepMinBlockSize << uint(i)
+}
+
+func ssdeepSumHash(c byte, h uint32) uint32 {
+	return (h * ssdeepHashPrime) ^ uint32(c)
+}
+
+func (s *ssdeepState) total() uint64 {
+	if s.written > s.size {
+		return s.written
+	}
+	return s.size
+}
+
+func (s *ssdeepState) Write(p []byte) (int, error) {
+	s.written += uint64(len(p))
+	for _, c := range p {
+		s.step(c)
+	}
+	return len(p), nil
+}
+
+func (s *ssdeepState) step(c byte) {
+
+	s.roll.hash(c)
+	h := uint64(s.roll.sum())
+
+	for i := s.bhstart; i < s.bhend; i++ {
+		s.bh[i].h = ssdeepSumHash(c, s.bh[i].h)
+		s.bh[i].halfh = ssdeepSumHash(c, s.bh[i].halfh)
+	}
+
+	for i := s.bhstart; i < s.bhend; i++ {
+		// a trigger point for this block size
+		if h%ssdeepBlockSize(i) != ssdeepBlockSize(i)-1 {
+			break
+		}
+		if s.bh[i].dlen == 0 {
+			s.forkBlockHash()
+		}
+		b := &s.bh[i]
+		b.digest[b.dlen] = ssdeepBase64[b.h%64]
+		b.halfdigest = ssdeepBase64[b.halfh%64]
+		if b.dlen < ssdeepSpamSumLength-1 {
+			b.dlen++
+			b.digest[b.dlen] = 0
+			b.h = ssdeepHashInit
+			if b.dlen < ssdeepSpamSumLength/2 {
+				b.halfh = ssdeepHashInit
+				b.halfdigest = 0
+			}
+		} else {
+			s.reduceBlockHash()
+		}
+	}
+}
+
+// forkBlockHash starts the next larger block size from the state of the current largest
+func (s *ssdeepState) forkBlockHash() {
+	if s.bhend >= ssdeepBlockHashes {
+		return
+	}
+	s.bh[s.bhend] = ssdeepBlockHash{h: s.bh[s.bhend-1].h, halfh: s.bh[s.bhend-1].halfh}
+	s.bhend++
+}
+
+// reduceBlockHash drops the smallest block size once it can no longer be chosen
+func (s *ssdeepState) reduceBlockHash() {
+	if s.bhend-s.bhstart < 2 {
+		return
+	}
+	if ssdeepBlockSize(s.bhstart)*ssdeepSpamSumLength >= s.total() {
+		return
+	}
+	if s.bh[s.bhstart+1].dlen < ssdeepSpamSumLength/2 {
+		return
+	}
+	s.bhstart++
+}
+
+// Sum returns the hash as blocksize:digest:digest
+func (s *ssdeepState) Sum() string {
+
+	bi := s.bhstart
+	h := s.roll.sum()
+
+	for ssdeepBlockSize(bi)*ssdeepSpamSumLength < s.total() {
+		bi++
+		if bi >= ssdeepBlockHashes {
+			return ""
+		}
+	}
+	if bi >= s.bhend {
+		bi = s.bhend - 1
+	}
+	for bi > s.bhstart && s.bh[bi].dlen < ssdeepSpamSumLength/2 {
+		bi--
+	}
+
+	var result strings.Builder
+	result.WriteString(strconv.FormatUint(ssdeepBlockSize(bi), 10))
+	result.WriteByte(':')
+
+	b := &s.bh[bi]
+	result.Write(b.digest[:b.dlen])
+	if h != 0 {
+		result.WriteByte(ssdeepBase64[b.h%64])
+	} else if b.digest[b.dlen] != 0 {
+		result.WriteByte(b.digest[b.dlen])
+	}
+	result.WriteByte(':')
+
+	if bi < s.bhend-1 {
+		b = &s.bh[bi+1]
+		n := b.dlen
+		if n > ssdeepSpamSumLength/2-1 {
+			n = ssdeepSpamSumLength/2 - 1
+		}
+		result.Write(b.digest[:n])
+		if h != 0 {
+			result.WriteByte(ssdeepBase64[b.halfh%64])
+		} else if b.halfdigest != 0 {
+			result.WriteByte(b.halfdigest)
+		}
+	} else if h != 0 {
+		result.WriteByte(ssdeepBase64[b.h%64])
+	}
+
+	return result.String()
+}
+
+// parseSSDeep splits a hash into its block size and two digests
+func parseSSDeep(hash string) (uint64, string, string, error) {
+
+	parts := strings.SplitN(strings.TrimSpace(hash), ":", 3)
+	if len(parts) != 3 {
+		return 0, "", "", fmt.Errorf("invalid ssdeep hash %q", hash)
+	}
+	blockSize, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || blockSize < ssdeepMinBlockSize {
+		return 0, "", "", fmt.Errorf("invalid ssdeep block size in %q", hash)
+	}
+
+	// the second digest may be followed by a file name
+	second := parts[2]
+	if i := strings.IndexByte(second, ','); i >= 0 {
+		second = second[:i]
+	}
+
+	return blockSize, parts[1], second, nil
+}
+
+// compareSSDeep scores the similarity of two ssdeep hashes from 0 to 100
+func compareSSDeep(a string, b string) (int, error) {
+
+	bs1, a1, a2, err := parseSSDeep(a)
+	if err != nil {
+		return 0, err
+	}
+	bs2, b1, b2, err := parseSSDeep(b)
+	if err != nil {
+		return 0, err
+	}
+
+	// only hashes of the same or adjacent block sizes can be compared
+	if bs1 != bs2 && bs1 != bs2*2 && bs2 != bs1*2 {
+		return 0, nil
+	}
+
+	a1, a2 = eliminateSequences(a1), eliminateSequences(a2)
+	b1, b2 = eliminateSequences(b1), eliminateSequences(b2)
+
+	if bs1 == bs2 && a1 == b1 {
+		return 100, nil
+	}
+
+	switch {
+	case bs1 == bs2:
+		score1 := scoreStrings(a1, b1, bs1)
+		score2 := scoreStrings(a2, b2, bs1*2)
+		if score2 > score1 {
+			return score2, nil
+		}
+		return score1, nil
+	case bs1 == bs2*2:
+		return scoreStrings(a1, b2, bs1), nil
+	default:
+		return scoreStrings(a2, b1, bs2), nil
+	}
+}
+
+// eliminateSequences shortens runs of more than three identical characters to three
+func eliminateSequences(str string) string {
+
+	var result []byte
+	for i := 0; i < len(str); i++ {
+		if i >= 3 && str[i] == str[i-1] && str[i] == str[i-2] && str[i] == str[i-3] {
+			continue
+		}
+		result = append(result, str[i])
+	}
+
+	return string(result)
+}
+
+func scoreStrings(s1 string, s2 string, blockSize uint64) int {
+
+	if len(s1) > ssdeepSpamSumLength || len(s2) > ssdeepSpamSumLength {
+		return 0
+	}
+
+	// hashes without a rolling window length substring in common are unrelated
+	if !hasCommonSubstring(s1, s2) {
+		return 0
+	}
+
+	score := uint64(editDistance(s1, s2))
+	score = (score * ssdeepSpamSumLength) / uint64(len(s1)+len(s2))
+	score = (100 * score) / ssdeepSpamSumLength
+	if score >= 100 {
+		return 0
+	}
+	score = 100 - score
+
+	// small block sizes cap the score so short inputs do not look alike by chance
+	if blockSize >= (99+ssdeepRollingWindow)/ssdeepRollingWindow*ssdeepMinBlockSize {
+		return int(score)
+	}
+	shortest := len(s1)
+	if len(s2) < shortest {
+		shortest = len(s2)
+	}
+	if limit := blockSize / ssdeepMinBlockSize * uint64(shortest); score > limit {
+		score = limit
+	}
+
+	return int(score)
+}
+
+func hasCommonSubstring(s1 string, s2 string) bool {
+	if len(s1) < ssdeepRollingWindow || len(s2) < ssdeepRollingWindow {
+		return false
+	}
+	for i := 0; i+ssdeepRollingWindow <= len(s1); i++ {
+		if strings.Contains(s2, s1[i:i+ssdeepRollingWindow]) {
+			return true
+		}
+	}
+	return false
+}
+
+// editDistance is the weighted levenshtein distance ssdeep uses, where a
+// substitution costs as much as an insertion and a deletion
+func editDistance(s1 string, s2 string) int {
+
+	previous := make([]int, len(s2)+1)
+	current := make([]int, len(s2)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(s1); i++ {
+		current[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := 2
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			best := previous[j-1] + cost
+			if previous[j]+1 < best {
+				best = previous[j] + 1
+			}
+			if current[j-1]+1 < best {
+				best = current[j-1] + 1
+			}
+			current[j] = best
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(s2)]
+}
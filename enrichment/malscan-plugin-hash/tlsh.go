@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// TLSH (trend micro locality sensitive hash) with 128 buckets and a one byte
+// checksum, the "T1" prefixed 72 character form the reference tools print
+const (
+	tlshBuckets       = 256
+	tlshEffective     = 128
+	tlshCodeSize      = 32
+	tlshWindow        = 5
+	tlshMinDataLength = 50
+)
+
+// tlshPearson is the pearson hashing permutation of the reference implementation
+var tlshPearson = [256]byte{
+	1, 87, 49, 12, 176, 178, 102, 166, 121, 193, 6, 84, 249, 230, 44, 163,
+	14, 197, 213, 181, 161, 85, 218, 80, 64, 239, 24, 226, 236, 142, 38, 200,
+	110, 177, 104, 103, 141, 253, 255, 50, 77, 101, 81, 18, 45, 96, 31, 222,
+	25, 107, 190, 70, 86, 237, 240, 34, 72, 242, 20, 214, 244, 227, 149, 235,
+	97, 234, 57, 22, 60, 250, 82, 175, 208, 5, 127, 199, 111, 62, 135, 248,
+	174, 169, 211, 58, 66, 154, 106, 195, 245, 171, 17, 187, 182, 179, 0, 243,
+	132, 56, 148, 75, 128, 133, 158, 100, 130, 126, 91, 13, 153, 246, 216, 219,
+	119, 68, 223, 78, 83, 88, 201, 99, 122, 11, 92, 32, 136, 114, 52, 10,
+	138, 30, 48, 183, 156, 35, 61, 26, 143, 74, 251, 94, 129, 162, 63, 152,
+	170, 7, 115, 167, 241, 206, 3, 150, 55, 59, 151, 220, 90, 53, 23, 131,
+	125, 173, 15, 238, 79, 95, 89, 16, 105, 137, 225, 224, 217, 160, 37, 123,
+	118, 73, 2, 157, 46, 116, 9, 145, 134, 228, 207, 212, 202, 215, 69, 229,
+	27, 188, 67, 124, 168, 252, 42, 4, 29, 108, 21, 247, 19, 205, 39, 203,
+	233, 40, 186, 147, 198, 192, 155, 33, 164, 191, 98, 204, 165, 180, 117, 76,
+	140, 36, 210, 172, 41, 54, 159, 8, 185, 232, 113, 196, 231, 47, 146, 120,
+	51, 65, 28, 144, 254, 221, 93, 189, 194, 139, 112, 43, 71, 109, 184, 209,
+}
+
+func tlshMapping(salt, i, j, k byte) byte {
+	h := tlshPearson[salt]
+	h = tlshPearson[h^i]
+	h = tlshPearson[h^j]
+	h = tlshPearson[h^k]
+	return h
+}
+
+// tlshState is an io.Writer computing the TLSH of everything written to it
+type tlshState struct {
+	buckets  [tlshBuckets]uint32
+	window   [tlshWindow]byte
+	checksum byte
+	length   uint64
+}
+
+func newTLSH() *tlshState {
+	return &tlshState{}
+}
+
+func (t *tlshState) Write(p []byte) (int, error) {
+
+	for _, c := range p {
+		n := t.length % tlshWindow
+		t.window[n] = c
+		t.length++
+
+		// every 5 byte window adds six of its byte triplets to the buckets
+		if t.length < tlshWindow {
+			continue
+		}
+		b0 := c
+		b1 := t.window[(n+4)%tlshWindow]
+		b2 := t.window[(n+3)%tlshWindow]
+		b3 := t.window[(n+2)%tlshWindow]
+		b4 := t.window[(n+1)%tlshWindow]
+
+		t.checksum = tlshMapping(0, b0, b1, t.checksum)
+
+		t.buckets[tlshMapping(2, b0, b1, b2)]++
+		t.buckets[tlshMapping(3, b0, b1, b3)]++
+		t.buckets[tlshMapping(5, b0, b2, b3)]++
+		t.buckets[tlshMapping(7, b0, b2, b4)]++
+		t.buckets[tlshMapping(11, b0, b1, b4)]++
+		t.buckets[tlshMapping(13, b0, b3, b4)]++
+	}
+
+	return len(p), nil
+}
+
+// Sum returns the hash, or an empty string when the input is too short or too
+// uniform for a meaningful one
+func (t *tlshState) Sum() string {
+
+	if t.length < tlshMinDataLength {
+		return ""
+	}
+
+	sorted := make([]uint32, tlshEffective)
+	copy(sorted, t.buckets[:tlshEffective])
+	nonZero := 0
+	for _, count := range sorted {
+		if count != 0 {
+			nonZero++
+		}
+	}
+	if nonZero <= tlshEffective/2 {
+		return ""
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	q1, q2, q3 := sorted[tlshEffective/4-1], sorted[tlshEffective/2-1], sorted[tlshEffective-tlshEffective/4-1]
+	if q3 == 0 {
+		return ""
+	}
+
+	var code [tlshCodeSize]byte
+	for i := range code {
+		var h byte
+		for j := uint(0); j < 4; j++ {
+			k := t.buckets[4*i+int(j)]
+			switch {
+			case q3 < k:
+				h += 3 << (j * 2)
+			case q2 < k:
+				h += 2 << (j * 2)
+			case q1 < k:
+				h += 1 << (j * 2)
+			}
+		}
+		code[i] = h
+	}
+
+	q1ratio := byte(uint32(float32(q1*100)/float32(q3)) % 16)
+	q2ratio := byte(uint32(float32(q2*100)/float32(q3)) % 16)
+
+	digest := make([]byte, 0, 3+tlshCodeSize)
+	digest = append(digest, swapNibbles(t.checksum), swapNibbles(tlshLength(t.length)), swapNibbles(q2ratio<<4|q1ratio))
+	for i := tlshCodeSize - 1; i >= 0; i-- {
+		digest = append(digest, code[i])
+	}
+
+	return "T1" + strings.ToUpper(hex.EncodeToString(digest))
+}
+
+// the reference implementation's truncated logarithms, math.Log's exact values
+// floor differently for some lengths
+const (
+	tlshLog15 = 0.4054651
+	tlshLog13 = 0.26236426
+	tlshLog11 = 0.095310180
+)
+
+// tlshLength captures the input length on a logarithmic scale in one byte
+func tlshLength(length uint64) byte {
+
+	l := math.Log(float64(float32(length)))
+
+	var i float64
+	switch {
+	case length <= 656:
+		i = math.Floor(l / tlshLog15)
+	case length <= 3199:
+		i = math.Floor(l/tlshLog13 - 8.72777)
+	default:
+		i = math.Floor(l/tlshLog11 - 62.5472)
+	}
+
+	return byte(int(i) & 0xff)
+}
+
+func swapNibbles(b byte) byte {
+	return b<<4 | b>>4
+}
+
+type tlshDigest struct {
+	checksum byte
+	length   byte
+	q1ratio  byte
+	q2ratio  byte
+	code     []byte
+}
+
+// parseTLSH decodes a hash, with or without its version prefix
+func parseTLSH(hash string) (*tlshDigest, error) {
+
+	hash = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(hash)), "T1")
+	raw, err := hex.DecodeString(hash)
+	if err != nil || len(raw) != 3+tlshCodeSize {
+		return nil, fmt.Errorf("invalid tlsh hash %q", hash)
+	}
+
+	q := swapNibbles(raw[2])
+	return &tlshDigest{
+		checksum: swapNibbles(raw[0]),
+		length:   swapNibbles(raw[1]),
+		q1ratio:  q & 0x0f,
+		q2ratio:  q >> 4,
+		code:     raw[3:],
+	}, nil
+}
+
+// compareTLSH returns the distance between two hashes, 0 for identical inputs
+// and growing with their difference
+func compareTLSH(a string, b string) (int, error) {
+
+	x, err := parseTLSH(a)
+	if err != nil {
+		return 0, err
+	}
+	y, err := parseTLSH(b)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := 0
+
+	if ldiff := modDiff(int(x.length), int(y.length), 256); ldiff <= 1 {
+		diff += ldiff
+	} else {
+		diff += ldiff * 12
+	}
+
+	for _, qdiff := range []int{modDiff(int(x.q1ratio), int(y.q1ratio), 16), modDiff(int(x.q2ratio), int(y.q2ratio), 16)} {
+		if qdiff <= 1 {
+			diff += qdiff
+		} else {
+			diff += (qdiff - 1) * 12
+		}
+	}
+
+	if x.checksum != y.checksum {
+		diff++
+	}
+
+	// the body differs by two bits per bucket, a jump from the lowest to the
+	// highest quartile counts double
+	for i := range x.code {
+		for j := uint(0); j < 8; j += 2 {
+			d := int(x.code[i]>>j&3) - int(y.code[i]>>j&3)
+			if d < 0 {
+				d = -d
+			}
+			if d == 3 {
+				d = 6
+			}
+			diff += d
+		}
+	}
+
+	return diff, nil
+}
+
+// modDiff is the distance between x and y on a circle of size r
+func modDiff(x int, y int, r int) int {
+	dl, dr := x-y, y+r-x
+	if y > x {
+		dl, dr = y-x, x+r-y
+	}
+	if dl > dr {
+		return dr
+	}
+	return dl
+}
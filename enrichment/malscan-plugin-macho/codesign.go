@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// code signing blob magics, the signature is big endian whatever the architecture
+const (
+	csSuperBlob             = 0xfade0cc0
+	csCodeDirectory         = 0xfade0c02
+	csRequirements          = 0xfade0c01
+	csEntitlements          = 0xfade7171
+	csEntitlementsDER       = 0xfade7172
+	csSignature             = 0xfade0b01
+	csAdhoc                 = 0x2
+	csRuntime               = 0x10000
+	codeDirectoryTeamIDFrom = 0x20200
+)
+
+// CodeSignature json object
+type CodeSignature struct {
+	Offset          uint32            `json:"offset" structs:"offset"`
+	Size            uint32            `json:"size" structs:"size"`
+	Identifier      string            `json:"identifier" structs:"identifier"`
+	TeamID          string            `json:"team_id,omitempty" structs:"team_id"`
+	Flags           []string          `json:"flags" structs:"flags"`
+	AdHoc           bool              `json:"adhoc" structs:"adhoc"`
+	HardenedRuntime bool              `json:"hardened_runtime" structs:"hardened_runtime"`
+	CDHashes        map[string]string `json:"cdhashes" structs:"cdhashes"`
+	Requirements    bool              `json:"requirements" structs:"requirements"`
+	Signed          bool              `json:"signed" structs:"signed"`
+	Certificates    []Certificate     `json:"certificates,omitempty" structs:"certificates"`
+	Entitlements    string            `json:"entitlements,omitempty" structs:"entitlements"`
+	EntitlementKeys []string          `json:"entitlement_keys,omitempty" structs:"entitlement_keys"`
+	Error           string            `json:"error,omitempty" structs:"error"`
+}
+
+// Certificate json object
+type Certificate struct {
+	Subject string `json:"subject" structs:"subject"`
+	Issuer  string `json:"issuer" structs:"issuer"`
+	Serial  string `json:"serial" structs:"serial"`
+	SHA1    string `json:"sha1" structs:"sha1"`
+}
+
+// parseCodeSignature reads the identity, flags, entitlements and signing
+// certificates from an LC_CODE_SIGNATURE super blob
+func parseCodeSignature(blob []byte) *CodeSignature {
+
+	signature := &CodeSignature{Flags: []string{}, CDHashes: map[string]string{}}
+
+	if len(blob) < 12 || binary.BigEndian.Uint32(blob) != csSuperBlob {
+		signature.Error = "no code signing super blob"
+		return signature
+	}
+
+	count := binary.BigEndian.Uint32(blob[8:])
+	for i := uint32(0); i < count && 12+int(i)*8+8 <= len(blob); i++ {
+		offset := binary.BigEndian.Uint32(blob[12+i*8+4:])
+		if int(offset)+8 > len(blob) {
+			continue
+		}
+		length := binary.BigEndian.Uint32(blob[offset+4:])
+		end := int(offset) + int(length)
+		if end > len(blob) || length < 8 {
+			end = len(blob)
+		}
+		entry := blob[offset:end]
+
+		switch binary.BigEndian.Uint32(entry) {
+		case csCodeDirectory:
+			signature.codeDirectory(entry)
+		case csRequirements:
+			signature.Requirements = true
+		case csEntitlements:
+			signature.Entitlements = string(entry[8:])
+			signature.EntitlementKeys = entitlementKeys(entry[8:])
+		case csEntitlementsDER:
+			if len(signature.Entitlements) == 0 {
+				signature.Entitlements = hex.EncodeToString(entry[8:])
+			}
+		case csSignature:
+			signature.Certificates = cmsCertificates(entry[8:])
+			signature.Signed = len(signature.Certificates) != 0
+		}
+	}
+
+	return signature
+}
+
+// codeDirectory reads one code directory, the first one gives the identity and
+// every one adds its cdhash
+func (s *CodeSignature) codeDirectory(cd []byte) {
+
+	if len(cd) < 44 {
+		return
+	}
+	version := binary.BigEndian.Uint32(cd[8:])
+	flags := binary.BigEndian.Uint32(cd[12:])
+	identOffset := binary.BigEndian.Uint32(cd[20:])
+	hashType := cd[37]
+
+	if len(s.Identifier) == 0 {
+		s.Identifier = nullTerminated(cd, identOffset)
+		if version >= codeDirectoryTeamIDFrom && len(cd) >= 52 {
+			if teamOffset := binary.BigEndian.Uint32(cd[48:]); teamOffset != 0 {
+				s.TeamID = nullTerminated(cd, teamOffset)
+			}
+		}
+		for _, flag := range codeSigningFlags {
+			if flags&flag.value != 0 {
+				s.Flags = append(s.Flags, flag.name)
+			}
+		}
+		s.AdHoc = flags&csAdhoc != 0
+		s.HardenedRuntime = flags&csRuntime != 0
+	}
+
+	// a cdhash is the code directory's hash truncated to 20 bytes
+	var sum []byte
+	name := ""
+	switch hashType {
+	case 1:
+		h := sha1.Sum(cd)
+		sum, name = h[:], "sha1"
+	case 2, 3:
+		h := sha256.Sum256(cd)
+		sum, name = h[:], "sha256"
+	case 4:
+		h := sha512.Sum384(cd)
+		sum, name = h[:], "sha384"
+	default:
+		return
+	}
+	s.CDHashes[name] = hex.EncodeToString(sum[:20])
+}
+
+// entitlementKeys lists the top level keys of an entitlements plist
+func entitlementKeys(plist []byte) []string {
+
+	var keys []string
+
+	decoder := xml.NewDecoder(bytes.NewReader(plist))
+	depth := 0
+	inKey := false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			// plist > dict > key
+			inKey = depth == 3 && t.Name.Local == "key"
+		case xml.EndElement:
+			depth--
+			inKey = false
+		case xml.CharData:
+			if inKey {
+				keys = append(keys, string(t))
+			}
+		}
+	}
+
+	return keys
+}
+
+// cms structures, only enough to reach the certificates
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type cmsSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+// cmsCertificates returns the certificates embedded in the cms signature, an
+// ad-hoc signature has an empty one
+func cmsCertificates(cms []byte) []Certificate {
+
+	var certs []Certificate
+
+	if len(cms) == 0 {
+		return nil
+	}
+
+	var info cmsContentInfo
+	if _, err := asn1.Unmarshal(cms, &info); err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing code signature cms"))
+		return nil
+	}
+	var signed cmsSignedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing code signature signed data"))
+		return nil
+	}
+
+	rest := signed.Certificates.Bytes
+	for len(rest) != 0 {
+		var raw asn1.RawValue
+		var err error
+		rest, err = asn1.Unmarshal(rest, &raw)
+		if err != nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing certificate"))
+			continue
+		}
+		sum := sha1.Sum(cert.Raw)
+		certs = append(certs, Certificate{
+			Subject: cert.Subject.String(),
+			Issuer:  cert.Issuer.String(),
+			Serial:  fmt.Sprintf("%x", cert.SerialNumber),
+			SHA1:    hex.EncodeToString(sum[:]),
+		})
+	}
+
+	return certs
+}
+
+func nullTerminated(data []byte, offset uint32) string {
+	if int(offset) >= len(data) {
+		return ""
+	}
+	str := data[offset:]
+	if i := bytes.IndexByte(str, 0); i >= 0 {
+		str = str[:i]
+	}
+	return string(str)
+}
+
+var codeSigningFlags = []struct {
+	value uint32
+	name  string
+}{
+	{0x1, "valid"},
+	{0x2, "adhoc"},
+	{0x4, "get-task-allow"},
+	{0x100, "hard"},
+	{0x200, "kill"},
+	{0x400, "check-expiration"},
+	{0x800, "restrict"},
+	{0x1000, "enforcement"},
+	{0x2000, "library-validation"},
+	{0x10000, "runtime"},
+	{0x20000, "linker-signed"},
+}
@@ -0,0 +1,9 @@
+module github.com/LiamHellend/malscan-plugin-macho
+
+go 1.13
+
+require (
+	github.com/pkg/errors v0.9.1
+	github.com/sirupsen/logrus v1.4.2
+	github.com/urfave/cli v1.22.2
+)
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"debug/macho"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// load commands read from their raw bytes, debug/macho only decodes a few of them
+const (
+	lcUnixThread        = 0x5
+	lcLoadDylib         = 0xc
+	lcUUID              = 0x1b
+	lcCodeSignature     = 0x1d
+	lcLazyLoadDylib     = 0x20
+	lcEncryptionInfo    = 0x21
+	lcVersionMinMacOSX  = 0x24
+	lcVersionMinIOS     = 0x25
+	lcVersionMinTVOS    = 0x2f
+	lcVersionMinWatchOS = 0x30
+	lcEncryptionInfo64  = 0x2c
+	lcBuildVersion      = 0x32
+	lcLoadWeakDylib     = 0x80000018
+	lcRpath             = 0x8000001c
+	lcReexportDylib     = 0x8000001f
+	lcLoadUpwardDylib   = 0x80000023
+	lcMain              = 0x80000028
+)
+
+// analyzeMachO parses a thin or universal mach-o file with debug/macho
+func analyzeMachO(file string) ResultsData {
+
+	results := ResultsData{Error: "nil"}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+
+	fat, err := macho.NewFatFile(bytes.NewReader(data))
+	if err == nil {
+		defer fat.Close()
+		results.Universal = true
+		for _, arch := range fat.Arches {
+			slice := sliceBytes(data, uint64(arch.Offset), uint64(arch.Size))
+			results.Architectures = append(results.Architectures, analyzeArch(arch.File, slice, uint64(arch.Offset)))
+		}
+		return results
+	}
+	if err != macho.ErrNotFat {
+		// java class files share the universal magic, so also try the file as a thin binary
+		log.Debug(errors.Wrap(err, "Error while parsing universal header"))
+	}
+
+	f, err := macho.NewFile(bytes.NewReader(data))
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing mach-o file"))
+		results.Error = err.Error()
+		return results
+	}
+	defer f.Close()
+
+	results.Architectures = append(results.Architectures, analyzeArch(f, data, 0))
+
+	return results
+}
+
+// analyzeArch reports one architecture, data holds only that architecture's bytes
+func analyzeArch(f *macho.File, data []byte, offset uint64) Architecture {
+
+	arch := Architecture{
+		CPU:          cpuName(f.Cpu, f.SubCpu),
+		Type:         fileTypes[uint32(f.Type)],
+		Flags:        headerFlags(f.Flags),
+		Offset:       offset,
+		Size:         uint64(len(data)),
+		LoadCommands: []LoadCommand{},
+		Dylibs:       []Dylib{},
+		Imports:      []string{},
+		Segments:     []Segment{},
+	}
+	if len(arch.Type) == 0 {
+		arch.Type = fmt.Sprintf("0x%x", uint32(f.Type))
+	}
+
+	order := f.ByteOrder
+	var entryOffset uint64
+	hasMain := false
+
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if len(raw) < 8 {
+			continue
+		}
+		cmd := order.Uint32(raw[0:])
+		arch.LoadCommands = append(arch.LoadCommands, LoadCommand{Command: commandName(cmd), Size: uint32(len(raw))})
+
+		switch cmd {
+		case lcLoadDylib, lcLoadWeakDylib, lcReexportDylib, lcLazyLoadDylib, lcLoadUpwardDylib:
+			if len(raw) >= 24 {
+				arch.Dylibs = append(arch.Dylibs, Dylib{
+					Name:                 cString(raw, order.Uint32(raw[8:])),
+					Kind:                 dylibKinds[cmd],
+					CurrentVersion:       version(order.Uint32(raw[16:])),
+					CompatibilityVersion: version(order.Uint32(raw[20:])),
+				})
+			}
+		case lcRpath:
+			if len(raw) >= 12 {
+				arch.Rpaths = append(arch.Rpaths, cString(raw, order.Uint32(raw[8:])))
+			}
+		case lcUUID:
+			if len(raw) >= 24 {
+				u := raw[8:24]
+				arch.UUID = fmt.Sprintf("%X-%X-%X-%X-%X", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+			}
+		case lcMain:
+			if len(raw) >= 16 {
+				entryOffset = order.Uint64(raw[8:])
+				hasMain = true
+				arch.EntryCommand = "LC_MAIN"
+			}
+		case lcUnixThread:
+			if pc, ok := threadPC(f.Cpu, order, raw); ok {
+				arch.EntryPoint = hexNumber(pc)
+				arch.EntryCommand = "LC_UNIXTHREAD"
+			}
+		case lcCodeSignature:
+			if len(raw) >= 16 {
+				dataOff, dataSize := order.Uint32(raw[8:]), order.Uint32(raw[12:])
+				arch.CodeSignature = parseCodeSignature(sliceBytes(data, uint64(dataOff), uint64(dataSize)))
+				arch.CodeSignature.Offset = dataOff
+				arch.CodeSignature.Size = dataSize
+			}
+		case lcEncryptionInfo, lcEncryptionInfo64:
+			if len(raw) >= 20 && order.Uint32(raw[16:]) != 0 {
+				arch.Encrypted = true
+			}
+		case lcVersionMinMacOSX, lcVersionMinIOS, lcVersionMinTVOS, lcVersionMinWatchOS:
+			if len(raw) >= 16 {
+				arch.MinOS = minVersionPlatforms[cmd] + " " + version(order.Uint32(raw[8:]))
+				arch.SDK = version(order.Uint32(raw[12:]))
+			}
+		case lcBuildVersion:
+			if len(raw) >= 20 {
+				platform := platforms[order.Uint32(raw[8:])]
+				if len(platform) == 0 {
+					platform = fmt.Sprint(order.Uint32(raw[8:]))
+				}
+				arch.MinOS = platform + " " + version(order.Uint32(raw[12:]))
+				arch.SDK = version(order.Uint32(raw[16:]))
+			}
+		}
+
+		if segment, ok := load.(*macho.Segment); ok {
+			arch.Segments = append(arch.Segments, newSegment(f, segment, data))
+		}
+	}
+
+	// LC_MAIN gives the entry point as an offset into __TEXT
+	if hasMain {
+		entry := entryOffset
+		if text := f.Segment("__TEXT"); text != nil {
+			entry += text.Addr
+		}
+		arch.EntryPoint = hexNumber(entry)
+	}
+
+	if imports, err := f.ImportedSymbols(); err == nil {
+		arch.Imports = append(arch.Imports, imports...)
+		sort.Strings(arch.Imports)
+	} else {
+		log.Debug(errors.Wrap(err, "Error while reading imported symbols"))
+	}
+
+	return arch
+}
+
+func newSegment(f *macho.File, segment *macho.Segment, data []byte) Segment {
+
+	s := Segment{
+		Name:       segment.Name,
+		Address:    hexNumber(segment.Addr),
+		MemorySize: segment.Memsz,
+		Offset:     hexNumber(segment.Offset),
+		FileSize:   segment.Filesz,
+		MaxProt:    protection(segment.Maxprot),
+		InitProt:   protection(segment.Prot),
+		Entropy:    entropy(sliceBytes(data, segment.Offset, segment.Filesz)),
+		Sections:   []Section{},
+	}
+
+	for _, section := range f.Sections {
+		if section.Seg != segment.Name {
+			continue
+		}
+		sec := Section{
+			Name:    section.Name,
+			Address: hexNumber(section.Addr),
+			Size:    section.Size,
+		}
+		// zero fill sections take no space in the file
+		if kind := section.Flags & 0xff; kind != 0x1 && kind != 0xc && kind != 0x12 && section.Offset != 0 {
+			sec.Entropy = entropy(sliceBytes(data, uint64(section.Offset), section.Size))
+		}
+		s.Sections = append(s.Sections, sec)
+	}
+
+	return s
+}
+
+// threadPC reads the program counter from an LC_UNIXTHREAD thread state
+func threadPC(cpu macho.Cpu, order binary.ByteOrder, raw []byte) (uint64, bool) {
+
+	// the state follows cmd, cmdsize, flavor and count
+	var at int
+	switch cpu {
+	case macho.Cpu386:
+		at = 16 + 10*4 // eip
+		if len(raw) >= at+4 {
+			return uint64(order.Uint32(raw[at:])), true
+		}
+		return 0, false
+	case macho.CpuAmd64:
+		at = 16 + 16*8 // rip
+	case macho.CpuArm64:
+		at = 16 + 32*8 // pc
+	case macho.CpuArm:
+		at = 16 + 15*4 // r15
+		if len(raw) >= at+4 {
+			return uint64(order.Uint32(raw[at:])), true
+		}
+		return 0, false
+	default:
+		return 0, false
+	}
+
+	if len(raw) < at+8 {
+		return 0, false
+	}
+	return order.Uint64(raw[at:]), true
+}
+
+// sliceBytes returns the part of data in [offset, offset+size) that lies inside the file
+func sliceBytes(data []byte, offset uint64, size uint64) []byte {
+	if offset >= uint64(len(data)) {
+		return nil
+	}
+	end := offset + size
+	if end > uint64(len(data)) || end < offset {
+		end = uint64(len(data))
+	}
+	return data[offset:end]
+}
+
+// cString reads the null terminated string at offset within a load command
+func cString(raw []byte, offset uint32) string {
+	if int(offset) >= len(raw) {
+		return ""
+	}
+	str := raw[offset:]
+	if i := bytes.IndexByte(str, 0); i >= 0 {
+		str = str[:i]
+	}
+	return string(str)
+}
+
+// version formats a packed xxxx.yy.zz version
+func version(v uint32) string {
+	return fmt.Sprintf("%d.%d.%d", v>>16, (v>>8)&0xff, v&0xff)
+}
+
+func protection(prot uint32) string {
+	perms := []byte("---")
+	if prot&0x1 != 0 {
+		perms[0] = 'r'
+	}
+	if prot&0x2 != 0 {
+		perms[1] = 'w'
+	}
+	if prot&0x4 != 0 {
+		perms[2] = 'x'
+	}
+	return string(perms)
+}
+
+func cpuName(cpu macho.Cpu, sub uint32) string {
+	switch cpu {
+	case macho.Cpu386:
+		return "i386"
+	case macho.CpuAmd64:
+		if sub&0xff == 8 {
+			return "x86_64h"
+		}
+		return "x86_64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		if sub&0xff == 2 {
+			return "arm64e"
+		}
+		return "arm64"
+	case macho.CpuPpc:
+		return "ppc"
+	case macho.CpuPpc64:
+		return "ppc64"
+	case 0x0200000c:
+		return "arm64_32"
+	}
+	return fmt.Sprintf("0x%x", uint32(cpu))
+}
+
+func headerFlags(flags uint32) []string {
+	names := []string{}
+	for _, flag := range machoFlags {
+		if flags&flag.value != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+func commandName(cmd uint32) string {
+	if name, ok := loadCommands[cmd]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", cmd)
+}
+
+var fileTypes = map[uint32]string{
+	0x1: "MH_OBJECT",
+	0x2: "MH_EXECUTE",
+	0x3: "MH_FVMLIB",
+	0x4: "MH_CORE",
+	0x5: "MH_PRELOAD",
+	0x6: "MH_DYLIB",
+	0x7: "MH_DYLINKER",
+	0x8: "MH_BUNDLE",
+	0x9: "MH_DYLIB_STUB",
+	0xa: "MH_DSYM",
+	0xb: "MH_KEXT_BUNDLE",
+	0xc: "MH_FILESET",
+}
+
+var machoFlags = []struct {
+	value uint32
+	name  string
+}{
+	{0x1, "MH_NOUNDEFS"},
+	{0x2, "MH_INCRLINK"},
+	{0x4, "MH_DYLDLINK"},
+	{0x8, "MH_BINDATLOAD"},
+	{0x10, "MH_PREBOUND"},
+	{0x20, "MH_SPLIT_SEGS"},
+	{0x80, "MH_TWOLEVEL"},
+	{0x100, "MH_FORCE_FLAT"},
+	{0x200, "MH_NOMULTIDEFS"},
+	{0x2000, "MH_SUBSECTIONS_VIA_SYMBOLS"},
+	{0x8000, "MH_WEAK_DEFINES"},
+	{0x10000, "MH_BINDS_TO_WEAK"},
+	{0x20000, "MH_ALLOW_STACK_EXECUTION"},
+	{0x40000, "MH_ROOT_SAFE"},
+	{0x80000, "MH_SETUID_SAFE"},
+	{0x100000, "MH_NO_REEXPORTED_DYLIBS"},
+	{0x200000, "MH_PIE"},
+	{0x400000, "MH_DEAD_STRIPPABLE_DYLIB"},
+	{0x800000, "MH_HAS_TLV_DESCRIPTORS"},
+	{0x1000000, "MH_NO_HEAP_EXECUTION"},
+	{0x2000000, "MH_APP_EXTENSION_SAFE"},
+}
+
+var dylibKinds = map[uint32]string{
+	lcLoadDylib:       "load",
+	lcLoadWeakDylib:   "weak",
+	lcReexportDylib:   "reexport",
+	lcLazyLoadDylib:   "lazy",
+	lcLoadUpwardDylib: "upward",
+}
+
+var minVersionPlatforms = map[uint32]string{
+	lcVersionMinMacOSX:  "macOS",
+	lcVersionMinIOS:     "iOS",
+	lcVersionMinTVOS:    "tvOS",
+	lcVersionMinWatchOS: "watchOS",
+}
+
+var platforms = map[uint32]string{
+	1:  "macOS",
+	2:  "iOS",
+	3:  "tvOS",
+	4:  "watchOS",
+	5:  "bridgeOS",
+	6:  "Mac Catalyst",
+	7:  "iOS Simulator",
+	8:  "tvOS Simulator",
+	9:  "watchOS Simulator",
+	10: "DriverKit",
+}
+
+var loadCommands = map[uint32]string{
+	0x1:        "LC_SEGMENT",
+	0x2:        "LC_SYMTAB",
+	0x3:        "LC_SYMSEG",
+	0x4:        "LC_THREAD",
+	0x5:        "LC_UNIXTHREAD",
+	0x6:        "LC_LOADFVMLIB",
+	0x7:        "LC_IDFVMLIB",
+	0x8:        "LC_IDENT",
+	0x9:        "LC_FVMFILE",
+	0xa:        "LC_PREPAGE",
+	0xb:        "LC_DYSYMTAB",
+	0xc:        "LC_LOAD_DYLIB",
+	0xd:        "LC_ID_DYLIB",
+	0xe:        "LC_LOAD_DYLINKER",
+	0xf:        "LC_ID_DYLINKER",
+	0x10:       "LC_PREBOUND_DYLIB",
+	0x11:       "LC_ROUTINES",
+	0x12:       "LC_SUB_FRAMEWORK",
+	0x13:       "LC_SUB_UMBRELLA",
+	0x14:       "LC_SUB_CLIENT",
+	0x15:       "LC_SUB_LIBRARY",
+	0x16:       "LC_TWOLEVEL_HINTS",
+	0x17:       "LC_PREBIND_CKSUM",
+	0x19:       "LC_SEGMENT_64",
+	0x1a:       "LC_ROUTINES_64",
+	0x1b:       "LC_UUID",
+	0x1d:       "LC_CODE_SIGNATURE",
+	0x1e:       "LC_SEGMENT_SPLIT_INFO",
+	0x20:       "LC_LAZY_LOAD_DYLIB",
+	0x21:       "LC_ENCRYPTION_INFO",
+	0x22:       "LC_DYLD_INFO",
+	0x24:       "LC_VERSION_MIN_MACOSX",
+	0x25:       "LC_VERSION_MIN_IPHONEOS",
+	0x26:       "LC_FUNCTION_STARTS",
+	0x27:       "LC_DYLD_ENVIRONMENT",
+	0x29:       "LC_DATA_IN_CODE",
+	0x2a:       "LC_SOURCE_VERSION",
+	0x2b:       "LC_DYLIB_CODE_SIGN_DRS",
+	0x2c:       "LC_ENCRYPTION_INFO_64",
+	0x2d:       "LC_LINKER_OPTION",
+	0x2e:       "LC_LINKER_OPTIMIZATION_HINT",
+	0x2f:       "LC_VERSION_MIN_TVOS",
+	0x30:       "LC_VERSION_MIN_WATCHOS",
+	0x31:       "LC_NOTE",
+	0x32:       "LC_BUILD_VERSION",
+	0x80000018: "LC_LOAD_WEAK_DYLIB",
+	0x8000001c: "LC_RPATH",
+	0x8000001f: "LC_REEXPORT_DYLIB",
+	0x80000022: "LC_DYLD_INFO_ONLY",
+	0x80000023: "LC_LOAD_UPWARD_DYLIB",
+	0x80000028: "LC_MAIN",
+	0x80000033: "LC_DYLD_EXPORTS_TRIE",
+	0x80000034: "LC_DYLD_CHAINED_FIXUPS",
+	0x80000035: "LC_FILESET_ENTRY",
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-macho/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "macho"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// MachO json object (this is what gets output)
+type MachO struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Universal     bool
+	Architectures []Architecture
+	Error         string `json:"error" structs:"error"`
+}
+
+// Architecture json object
+type Architecture struct {
+	CPU           string         `json:"cpu" structs:"cpu"`
+	Type          string         `json:"type" structs:"type"`
+	Flags         []string       `json:"flags" structs:"flags"`
+	Offset        uint64         `json:"offset" structs:"offset"`
+	Size          uint64         `json:"size" structs:"size"`
+	UUID          string         `json:"uuid,omitempty" structs:"uuid"`
+	MinOS         string         `json:"min_os,omitempty" structs:"min_os"`
+	SDK           string         `json:"sdk,omitempty" structs:"sdk"`
+	EntryPoint    string         `json:"entry_point,omitempty" structs:"entry_point"`
+	EntryCommand  string         `json:"entry_command,omitempty" structs:"entry_command"`
+	LoadCommands  []LoadCommand  `json:"load_commands" structs:"load_commands"`
+	Dylibs        []Dylib        `json:"dylibs" structs:"dylibs"`
+	Rpaths        []string       `json:"rpaths,omitempty" structs:"rpaths"`
+	Imports       []string       `json:"imports" structs:"imports"`
+	Segments      []Segment      `json:"segments" structs:"segments"`
+	Encrypted     bool           `json:"encrypted" structs:"encrypted"`
+	CodeSignature *CodeSignature `json:"code_signature" structs:"code_signature"`
+	Error         string         `json:"error,omitempty" structs:"error"`
+}
+
+// LoadCommand json object
+type LoadCommand struct {
+	Command string `json:"command" structs:"command"`
+	Size    uint32 `json:"size" structs:"size"`
+}
+
+// Dylib json object
+type Dylib struct {
+	Name                 string `json:"name" structs:"name"`
+	Kind                 string `json:"kind" structs:"kind"`
+	CurrentVersion       string `json:"current_version" structs:"current_version"`
+	CompatibilityVersion string `json:"compatibility_version" structs:"compatibility_version"`
+}
+
+// Segment json object
+type Segment struct {
+	Name       string    `json:"name" structs:"name"`
+	Address    string    `json:"address" structs:"address"`
+	MemorySize uint64    `json:"memory_size" structs:"memory_size"`
+	Offset     string    `json:"offset" structs:"offset"`
+	FileSize   uint64    `json:"file_size" structs:"file_size"`
+	MaxProt    string    `json:"max_prot" structs:"max_prot"`
+	InitProt   string    `json:"init_prot" structs:"init_prot"`
+	Entropy    float64   `json:"entropy" structs:"entropy"`
+	Sections   []Section `json:"sections" structs:"sections"`
+}
+
+// Section json object
+type Section struct {
+	Name    string  `json:"name" structs:"name"`
+	Address string  `json:"address" structs:"address"`
+	Size    uint64  `json:"size" structs:"size"`
+	Entropy float64 `json:"entropy" structs:"entropy"`
+}
+
+// AvScan performs the mach-o analysis, giving up after timeout seconds
+func AvScan(timeout int) MachO {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		// debug/macho panics on some malformed files
+		defer func() {
+			if r := recover(); r != nil {
+				done <- ResultsData{Error: fmt.Sprint(r)}
+			}
+		}()
+		done <- analyzeMachO(path)
+	}()
+
+	select {
+	case results := <-done:
+		return MachO{Results: results}
+	case <-ctx.Done():
+		return MachO{Results: ResultsData{Error: fmt.Sprintf("analysis of %s timed out", path)}}
+	}
+}
+
+// entropy returns the shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]float64
+	for _, b := range data {
+		counts[b]++
+	}
+
+	total := float64(len(data))
+	result := 0.0
+	for _, count := range counts {
+		if count != 0 {
+			p := count / total
+			result -= p * math.Log2(p)
+		}
+	}
+
+	return math.Round(result*1000) / 1000
+}
+
+func hexNumber(n uint64) string {
+	return fmt.Sprintf("0x%x", n)
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "MachO"
+	app.Usage = "Malscan mach-o plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			macho := AvScan(c.Int("timeout"))
+
+			// convert to JSON
+			machoJSON, _ := json.Marshal(macho)
+
+			fmt.Println(string(machoJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
@@ -0,0 +1,721 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/sha256"
+	_ "crypto/sha512" // registers sha384 and sha512 for crypto.Hash
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	directorySecurity = 4
+	certificatePKCS7  = 0x0002
+)
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSpcIndirectData  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidMessageDigest    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidTimestampToken   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidTSTInfo          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidSpcSpOpusInfo    = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 12}
+	oidNestedSignature  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
+	digestAlgorithms    = map[string]crypto.Hash{
+		"1.2.840.113549.2.5":     crypto.MD5,
+		"1.3.14.3.2.26":          crypto.SHA1,
+		"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+		"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+		"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+	}
+	hashNames = map[crypto.Hash]string{
+		crypto.MD5:    "MD5",
+		crypto.SHA1:   "SHA-1",
+		crypto.SHA256: "SHA-256",
+		crypto.SHA384: "SHA-384",
+		crypto.SHA512: "SHA-512",
+	}
+)
+
+// Signature json object
+type Signature struct {
+	DigestAlgorithm string        `json:"digest_algorithm" structs:"digest_algorithm"`
+	Digest          string        `json:"digest" structs:"digest"`
+	ComputedDigest  string        `json:"computed_digest" structs:"computed_digest"`
+	DigestValid     bool          `json:"digest_valid" structs:"digest_valid"`
+	SignatureValid  bool          `json:"signature_valid" structs:"signature_valid"`
+	Trusted         bool          `json:"trusted" structs:"trusted"`
+	TrustError      string        `json:"trust_error,omitempty" structs:"trust_error"`
+	ProgramName     string        `json:"program_name,omitempty" structs:"program_name"`
+	Signer          *Certificate  `json:"signer" structs:"signer"`
+	Chain           []Certificate `json:"chain" structs:"chain"`
+	Timestamp       *Timestamp    `json:"timestamp,omitempty" structs:"timestamp"`
+	Nested          int           `json:"nested_signatures,omitempty" structs:"nested_signatures"`
+	Error           string        `json:"error" structs:"error"`
+}
+
+// Certificate json object
+type Certificate struct {
+	Subject   string `json:"subject" structs:"subject"`
+	Issuer    string `json:"issuer" structs:"issuer"`
+	Serial    string `json:"serial" structs:"serial"`
+	SHA1      string `json:"sha1" structs:"sha1"`
+	SHA256    string `json:"sha256" structs:"sha256"`
+	NotBefore string `json:"not_before" structs:"not_before"`
+	NotAfter  string `json:"not_after" structs:"not_after"`
+}
+
+// Timestamp json object
+type Timestamp struct {
+	Type           string       `json:"type" structs:"type"`
+	Time           string       `json:"time" structs:"time"`
+	DigestValid    bool         `json:"digest_valid" structs:"digest_valid"`
+	SignatureValid bool         `json:"signature_valid" structs:"signature_valid"`
+	Trusted        bool         `json:"trusted" structs:"trusted"`
+	TrustError     string       `json:"trust_error,omitempty" structs:"trust_error"`
+	Signer         *Certificate `json:"signer" structs:"signer"`
+	signer         *x509.Certificate
+	certs          []*x509.Certificate
+}
+
+// pkcs7 structures, only the parts authenticode uses
+
+// contentInfo keeps the [0] wrapper of its content, the content itself is Content.Bytes
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerial           issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type spcIndirectData struct {
+	Data          asn1.RawValue
+	MessageDigest digestInfo
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type spcSpOpusInfo struct {
+	ProgramName asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint digestInfo
+	Serial         *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// authenticode extracts the file's Authenticode signature and verifies it against
+// the certificates in trustStore, it returns nil for unsigned files
+func authenticode(file string, trustStore string) *Signature {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return nil
+	}
+
+	image, err := openPE(data)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing pe file"))
+		return nil
+	}
+	defer image.file.Close()
+
+	dir := image.directory(directorySecurity)
+	if dir.VirtualAddress == 0 || dir.Size == 0 {
+		return nil
+	}
+
+	signature := &Signature{Error: "nil"}
+
+	// the security directory holds a file offset rather than an address
+	start, end := int(dir.VirtualAddress), int(dir.VirtualAddress)+int(dir.Size)
+	if end > len(data) || end < start {
+		signature.Error = "certificate table extends past the end of the file"
+		return signature
+	}
+
+	pkcs7 := certificateTable(data[start:end])
+	if pkcs7 == nil {
+		signature.Error = "no pkcs7 signature in certificate table"
+		return signature
+	}
+
+	if err := signature.verify(image, pkcs7, start, end, loadTrustStore(trustStore)); err != nil {
+		log.Debug(errors.Wrap(err, "Error while verifying authenticode signature"))
+		signature.Error = err.Error()
+	}
+
+	return signature
+}
+
+// certificateTable returns the first pkcs7 signed data entry in the table of WIN_CERTIFICATE structures
+func certificateTable(table []byte) []byte {
+
+	for len(table) >= 8 {
+		length := int(binary.LittleEndian.Uint32(table[0:]))
+		kind := binary.LittleEndian.Uint16(table[6:])
+		if length < 8 || length > len(table) {
+			return nil
+		}
+		if kind == certificatePKCS7 {
+			return table[8:length]
+		}
+		if align8(length) >= len(table) {
+			return nil
+		}
+		table = table[align8(length):]
+	}
+
+	return nil
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+// verify checks the signature's file digest, the signer's signature and its chain
+func (s *Signature) verify(image *peImage, pkcs7 []byte, start int, end int, roots *x509.CertPool) error {
+
+	var info contentInfo
+	if _, err := asn1.Unmarshal(pkcs7, &info); err != nil {
+		return errors.Wrap(err, "parsing pkcs7")
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return errors.Errorf("unexpected pkcs7 content type %s", info.ContentType)
+	}
+
+	var signed signedData
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil {
+		return errors.Wrap(err, "parsing signed data")
+	}
+	if !signed.ContentInfo.ContentType.Equal(oidSpcIndirectData) || len(signed.SignerInfos) != 1 {
+		return errors.New("not an authenticode signature")
+	}
+
+	var content asn1.RawValue
+	if _, err := asn1.Unmarshal(signed.ContentInfo.Content.Bytes, &content); err != nil {
+		return errors.Wrap(err, "parsing indirect data")
+	}
+	var indirect spcIndirectData
+	if _, err := asn1.Unmarshal(content.FullBytes, &indirect); err != nil {
+		return errors.Wrap(err, "parsing indirect data")
+	}
+
+	certs := parseCertificates(signed.Certificates.Bytes)
+	for _, cert := range certs {
+		s.Chain = append(s.Chain, newCertificate(cert))
+	}
+
+	// the file digest covers everything but the checksum, the security directory entry
+	// and the certificate table
+	hash, ok := digestAlgorithms[indirect.MessageDigest.Algorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return errors.Errorf("unsupported digest algorithm %s", indirect.MessageDigest.Algorithm.Algorithm)
+	}
+	s.DigestAlgorithm = hashNames[hash]
+	s.Digest = hex.EncodeToString(indirect.MessageDigest.Digest)
+
+	computed, err := image.authenticodeDigest(hash, start, end)
+	if err != nil {
+		return err
+	}
+	s.ComputedDigest = hex.EncodeToString(computed)
+	s.DigestValid = bytes.Equal(computed, indirect.MessageDigest.Digest)
+
+	signer := signed.SignerInfos[0]
+	signerCert := findCertificate(certs, signer.IssuerAndSerial)
+	if signerCert == nil {
+		return errors.New("signer certificate not found")
+	}
+	cert := newCertificate(signerCert)
+	s.Signer = &cert
+
+	attributes := parseAttributes(signer.AuthenticatedAttributes)
+	if opus, ok := attributes[oidSpcSpOpusInfo.String()]; ok {
+		s.ProgramName = programName(opus)
+	}
+
+	// the signer signs its attributes, whose message digest covers the indirect data content
+	s.SignatureValid = verifySigner(signerCert, signer, content.Bytes) == nil
+
+	unauthenticated := parseAttributes(signer.UnauthenticatedAttributes)
+	s.Timestamp = timestamp(unauthenticated, certs, signer.EncryptedDigest)
+
+	if nested, ok := unauthenticated[oidNestedSignature.String()]; ok {
+		s.Nested = len(nested)
+	}
+
+	if roots == nil {
+		s.TrustError = "no trusted certificates loaded"
+		if s.Timestamp != nil {
+			s.Timestamp.TrustError = s.TrustError
+		}
+		return nil
+	}
+
+	// check the chain at the time the file was timestamped, so expired certificates
+	// that were valid at signing time still verify, but only when a trusted
+	// timestamping authority vouches for that time
+	verifyAt := time.Now()
+	if s.Timestamp != nil && s.Timestamp.verify(roots) {
+		verifyAt, _ = time.Parse(time.RFC3339, s.Timestamp.Time)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+
+	_, err = signerCert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   verifyAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	})
+	if err != nil {
+		s.TrustError = err.Error()
+	} else {
+		s.Trusted = s.DigestValid && s.SignatureValid
+	}
+
+	return nil
+}
+
+// authenticodeDigest hashes the file as described by the Authenticode specification
+func (p *peImage) authenticodeDigest(hash crypto.Hash, start int, end int) ([]byte, error) {
+
+	header := int(binary.LittleEndian.Uint32(p.data[0x3c:]))
+	optional := header + 24
+	checksum := optional + 64
+	directories := optional + 96
+	if p.is64() {
+		directories = optional + 112
+	}
+	security := directories + directorySecurity*8
+
+	if security+8 > start || start > len(p.data) {
+		return nil, errors.New("certificate table overlaps the pe headers")
+	}
+
+	h := hash.New()
+	h.Write(p.data[:checksum])
+	h.Write(p.data[checksum+4 : security])
+	h.Write(p.data[security+8 : start])
+	h.Write(p.data[end:])
+
+	return h.Sum(nil), nil
+}
+
+// verify chains the timestamp signer to roots as a timestamping authority, the
+// timestamp is an unauthenticated attribute so anyone can add one with any time
+func (ts *Timestamp) verify(roots *x509.CertPool) bool {
+
+	switch {
+	case ts.signer == nil:
+		ts.TrustError = "timestamp signer certificate not found"
+		return false
+	case !ts.DigestValid || !ts.SignatureValid:
+		ts.TrustError = "timestamp signature does not verify"
+		return false
+	}
+
+	stampedAt, err := time.Parse(time.RFC3339, ts.Time)
+	if err != nil {
+		ts.TrustError = "timestamp has no signing time"
+		return false
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range ts.certs {
+		intermediates.AddCert(c)
+	}
+
+	_, err = ts.signer.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   stampedAt,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	})
+	if err != nil {
+		ts.TrustError = err.Error()
+		return false
+	}
+	ts.Trusted = true
+
+	return true
+}
+
+// verifySigner checks the message digest attribute against content and the signature over the attributes
+func verifySigner(cert *x509.Certificate, signer signerInfo, content []byte) error {
+
+	hash, ok := digestAlgorithms[signer.DigestAlgorithm.Algorithm.String()]
+	if !ok || !hash.Available() {
+		return errors.Errorf("unsupported digest algorithm %s", signer.DigestAlgorithm.Algorithm)
+	}
+
+	attributes := parseAttributes(signer.AuthenticatedAttributes)
+	digest, ok := attributes[oidMessageDigest.String()]
+	if !ok || len(digest) == 0 {
+		return errors.New("no message digest attribute")
+	}
+	var expected []byte
+	if _, err := asn1.Unmarshal(digest[0].FullBytes, &expected); err != nil {
+		return errors.Wrap(err, "parsing message digest")
+	}
+
+	h := hash.New()
+	h.Write(content)
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return errors.New("message digest does not match")
+	}
+
+	// the attributes are signed as a SET rather than with their implicit [0] tag
+	signedAttributes := append([]byte{}, signer.AuthenticatedAttributes.FullBytes...)
+	if len(signedAttributes) == 0 {
+		return errors.New("no authenticated attributes")
+	}
+	signedAttributes[0] = 0x31
+
+	algorithm := signatureAlgorithm(cert, hash)
+	if algorithm == x509.UnknownSignatureAlgorithm {
+		return errors.New("unsupported signature algorithm")
+	}
+
+	return cert.CheckSignature(algorithm, signedAttributes, signer.EncryptedDigest)
+}
+
+// timestamp reads a pkcs9 countersignature or an rfc3161 timestamp token and checks
+// it covers the signer's encrypted digest
+func timestamp(attributes map[string][]asn1.RawValue, certs []*x509.Certificate, encryptedDigest []byte) *Timestamp {
+
+	if values, ok := attributes[oidCounterSignature.String()]; ok && len(values) != 0 {
+		var counter signerInfo
+		if _, err := asn1.Unmarshal(values[0].FullBytes, &counter); err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing countersignature"))
+			return nil
+		}
+
+		ts := &Timestamp{Type: "pkcs9"}
+		if signingTime, ok := parseAttributes(counter.AuthenticatedAttributes)[oidSigningTime.String()]; ok && len(signingTime) != 0 {
+			var t time.Time
+			if _, err := asn1.Unmarshal(signingTime[0].FullBytes, &t); err == nil {
+				ts.Time = t.UTC().Format(time.RFC3339)
+			}
+		}
+
+		ts.certs = certs
+		if cert := findCertificate(certs, counter.IssuerAndSerial); cert != nil {
+			c := newCertificate(cert)
+			ts.Signer, ts.signer = &c, cert
+			ts.SignatureValid = verifySigner(cert, counter, encryptedDigest) == nil
+			ts.DigestValid = ts.SignatureValid
+		}
+
+		return ts
+	}
+
+	if values, ok := attributes[oidTimestampToken.String()]; ok && len(values) != 0 {
+		var info contentInfo
+		if _, err := asn1.Unmarshal(values[0].FullBytes, &info); err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing timestamp token"))
+			return nil
+		}
+		var signed signedData
+		if _, err := asn1.Unmarshal(info.Content.Bytes, &signed); err != nil || len(signed.SignerInfos) == 0 {
+			log.Debug(errors.Wrap(err, "Error while parsing timestamp signed data"))
+			return nil
+		}
+		if !signed.ContentInfo.ContentType.Equal(oidTSTInfo) {
+			return nil
+		}
+
+		// the token content is an octet string wrapping the TSTInfo
+		var content []byte
+		if _, err := asn1.Unmarshal(signed.ContentInfo.Content.Bytes, &content); err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing timestamp content"))
+			return nil
+		}
+		var tst tstInfo
+		if _, err := asn1.Unmarshal(content, &tst); err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing TSTInfo"))
+			return nil
+		}
+
+		ts := &Timestamp{Type: "rfc3161", Time: tst.GenTime.UTC().Format(time.RFC3339)}
+
+		if hash, ok := digestAlgorithms[tst.MessageImprint.Algorithm.Algorithm.String()]; ok && hash.Available() {
+			h := hash.New()
+			h.Write(encryptedDigest)
+			ts.DigestValid = bytes.Equal(h.Sum(nil), tst.MessageImprint.Digest)
+		}
+
+		ts.certs = append(parseCertificates(signed.Certificates.Bytes), certs...)
+		if cert := findCertificate(ts.certs, signed.SignerInfos[0].IssuerAndSerial); cert != nil {
+			c := newCertificate(cert)
+			ts.Signer, ts.signer = &c, cert
+			ts.SignatureValid = verifySigner(cert, signed.SignerInfos[0], content) == nil
+		}
+
+		return ts
+	}
+
+	return nil
+}
+
+// parseAttributes maps the attributes in an implicitly tagged SET by their type
+func parseAttributes(raw asn1.RawValue) map[string][]asn1.RawValue {
+
+	attributes := map[string][]asn1.RawValue{}
+
+	rest := raw.Bytes
+	for len(rest) != 0 {
+		var attr attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &attr)
+		if err != nil {
+			break
+		}
+
+		values := attr.Values.Bytes
+		for len(values) != 0 {
+			var value asn1.RawValue
+			values, err = asn1.Unmarshal(values, &value)
+			if err != nil {
+				break
+			}
+			attributes[attr.Type.String()] = append(attributes[attr.Type.String()], value)
+		}
+	}
+
+	return attributes
+}
+
+// programName reads the program name the publisher gave in SpcSpOpusInfo
+func programName(values []asn1.RawValue) string {
+
+	if len(values) == 0 {
+		return ""
+	}
+
+	var opus spcSpOpusInfo
+	if _, err := asn1.Unmarshal(values[0].FullBytes, &opus); err != nil || len(opus.ProgramName.Bytes) == 0 {
+		return ""
+	}
+
+	// SpcString is a [0] bmp string or a [1] ascii string
+	var name asn1.RawValue
+	if _, err := asn1.Unmarshal(opus.ProgramName.Bytes, &name); err != nil {
+		return ""
+	}
+	if name.Tag == 0 {
+		return decodeUTF16BE(name.Bytes)
+	}
+	return string(name.Bytes)
+}
+
+func decodeUTF16BE(data []byte) string {
+	swapped := make([]byte, len(data)&^1)
+	for i := 0; i+1 < len(data); i += 2 {
+		swapped[i], swapped[i+1] = data[i+1], data[i]
+	}
+	return decodeUTF16(swapped)
+}
+
+// parseCertificates parses each certificate in a SET on its own, so one the x509
+// package rejects does not hide the rest
+func parseCertificates(data []byte) []*x509.Certificate {
+
+	var certs []*x509.Certificate
+
+	for len(data) != 0 {
+		var raw asn1.RawValue
+		var err error
+		data, err = asn1.Unmarshal(data, &raw)
+		if err != nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			log.Debug(errors.Wrap(err, "Error while parsing certificate"))
+			continue
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs
+}
+
+func findCertificate(certs []*x509.Certificate, id issuerAndSerial) *x509.Certificate {
+	for _, cert := range certs {
+		if cert.SerialNumber.Cmp(id.Serial) == 0 && bytes.Equal(cert.RawIssuer, id.Issuer.FullBytes) {
+			return cert
+		}
+	}
+	return nil
+}
+
+func signatureAlgorithm(cert *x509.Certificate, hash crypto.Hash) x509.SignatureAlgorithm {
+
+	switch cert.PublicKeyAlgorithm {
+	case x509.RSA:
+		switch hash {
+		case crypto.MD5:
+			return x509.MD5WithRSA
+		case crypto.SHA1:
+			return x509.SHA1WithRSA
+		case crypto.SHA256:
+			return x509.SHA256WithRSA
+		case crypto.SHA384:
+			return x509.SHA384WithRSA
+		case crypto.SHA512:
+			return x509.SHA512WithRSA
+		}
+	case x509.ECDSA:
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512
+		}
+	}
+
+	return x509.UnknownSignatureAlgorithm
+}
+
+func newCertificate(cert *x509.Certificate) Certificate {
+	sha1sum := sha1.Sum(cert.Raw)
+	sha256sum := sha256.Sum256(cert.Raw)
+	return Certificate{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Serial:    fmt.Sprintf("%x", cert.SerialNumber),
+		SHA1:      hex.EncodeToString(sha1sum[:]),
+		SHA256:    hex.EncodeToString(sha256sum[:]),
+		NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
+
+// loadTrustStore reads the pem or der certificates in a file or directory, the
+// plugin never fetches roots or revocation lists so verification stays offline
+func loadTrustStore(store string) *x509.CertPool {
+
+	if len(store) == 0 {
+		return nil
+	}
+
+	var files []string
+	info, err := os.Stat(store)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading trust store"))
+		return nil
+	}
+	if info.IsDir() {
+		entries, err := ioutil.ReadDir(store)
+		if err != nil {
+			log.Debug(errors.Wrap(err, "Error while reading trust store"))
+			return nil
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(store, entry.Name()))
+			}
+		}
+	} else {
+		files = append(files, store)
+	}
+
+	pool := x509.NewCertPool()
+	count := 0
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while reading %s", file))
+			continue
+		}
+
+		if bytes.Contains(data, []byte("-----BEGIN")) {
+			for {
+				var block *pem.Block
+				block, data = pem.Decode(data)
+				if block == nil {
+					break
+				}
+				if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+					pool.AddCert(cert)
+					count++
+				}
+			}
+			continue
+		}
+
+		if cert, err := x509.ParseCertificate(data); err == nil {
+			pool.AddCert(cert)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+
+	log.Debugf("loaded %d trusted certificates from %s", count, store)
+
+	return pool
+}
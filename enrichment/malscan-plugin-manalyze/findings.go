@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Manalyze plugin levels, as written in the json output
+var levels = []string{"no_opinion", "safe", "suspicious", "malicious"}
+
+// Finding json object, the result of one manalyze analysis plugin
+type Finding struct {
+	Plugin  string   `json:"plugin" structs:"plugin"`
+	Level   string   `json:"level" structs:"level"`
+	Summary string   `json:"summary" structs:"summary"`
+	Details []string `json:"details,omitempty" structs:"details"`
+}
+
+type manalyzePlugin struct {
+	Level   json.RawMessage        `json:"level"`
+	Summary string                 `json:"summary"`
+	Output  map[string]interface{} `json:"plugin_output"`
+}
+
+// level converts the plugin level, written as a number or a name, to an index into levels
+func (p manalyzePlugin) level() int {
+
+	var number int
+	if err := json.Unmarshal(p.Level, &number); err == nil {
+		if number >= 0 && number < len(levels) {
+			return number
+		}
+		return 0
+	}
+
+	var name string
+	json.Unmarshal(p.Level, &name)
+	name = strings.ToLower(strings.Replace(strings.TrimSpace(name), " ", "_", -1))
+	for i, level := range levels {
+		if name == level {
+			return i
+		}
+	}
+
+	return 0
+}
+
+// findingsFromPlugins converts manalyze's plugin results into findings and an
+// overall verdict, the most severe level any plugin reported
+func findingsFromPlugins(plugins map[string]manalyzePlugin) (findings []Finding, verdict string) {
+
+	names := []string{}
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	worst := 0
+	for _, name := range names {
+		plugin := plugins[name]
+
+		level := plugin.level()
+		if level > worst {
+			worst = level
+		}
+
+		findings = append(findings, Finding{
+			Plugin:  name,
+			Level:   levels[level],
+			Summary: plugin.Summary,
+			Details: pluginDetails(plugin.Output),
+		})
+	}
+
+	return findings, levels[worst]
+}
+
+// pluginDetails flattens a plugin's output, keeping the label of entries that are not
+// just numbered info lines
+func pluginDetails(output map[string]interface{}) (details []string) {
+
+	keys := []string{}
+	for key := range output {
+		keys = append(keys, key)
+	}
+	// keep info_2 before info_10
+	sort.Slice(keys, func(i, j int) bool {
+		a, errA := strconv.Atoi(strings.TrimPrefix(keys[i], "info_"))
+		b, errB := strconv.Atoi(strings.TrimPrefix(keys[j], "info_"))
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		value := detailValue(output[key])
+		if strings.HasPrefix(key, "info_") {
+			details = append(details, value)
+		} else {
+			details = append(details, fmt.Sprintf("%s: %s", key, value))
+		}
+	}
+
+	return details
+}
+
+func detailValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return strings.Join(values, ", ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math/bits"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	richMarker = 0x68636952 // "Rich"
+	dansMarker = 0x536e6144 // "DanS"
+)
+
+// RichHeader json object
+type RichHeader struct {
+	Key     string      `json:"key" structs:"key"`
+	Valid   bool        `json:"valid" structs:"valid"`
+	Hash    string      `json:"hash" structs:"hash"`
+	Entries []RichEntry `json:"entries" structs:"entries"`
+}
+
+// RichEntry json object
+type RichEntry struct {
+	CompID  string `json:"comp_id" structs:"comp_id"`
+	Product uint16 `json:"product" structs:"product"`
+	Build   uint16 `json:"build" structs:"build"`
+	Count   uint32 `json:"count" structs:"count"`
+}
+
+// addPEHashes computes the imphash, Rich header and section hashes of the file
+// for results produced by manalyze
+func addPEHashes(file string, results *ResultsData) {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return
+	}
+
+	image, err := openPE(data)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing pe file"))
+		return
+	}
+	defer image.file.Close()
+
+	hashPE(image, results)
+}
+
+// hashPE fills the imphash, Rich header and per section hashes
+func hashPE(image *peImage, results *ResultsData) {
+
+	if imphash := imphash(image.imports()); len(imphash) != 0 {
+		results.Hashes.Imphash = imphash
+	}
+
+	results.RichHeader = richHeader(image.data)
+
+	for i, section := range results.Sections {
+		for _, s := range image.file.Sections {
+			if s.Name != section.Name || hexNumber(uint64(s.Offset)) != section.RawAddress {
+				continue
+			}
+			raw := image.raw(s)
+			md5sum := md5.Sum(raw)
+			sha256sum := sha256.Sum256(raw)
+			results.Sections[i].MD5 = hex.EncodeToString(md5sum[:])
+			results.Sections[i].SHA256 = hex.EncodeToString(sha256sum[:])
+			if section.Entropy == 0 {
+				results.Sections[i].Entropy = entropy(raw)
+			}
+			break
+		}
+	}
+}
+
+// imphash computes the import hash the way pefile does: the md5 of the lower
+// cased "library.function" list, with library extensions removed and functions
+// imported by ordinal resolved where the ordinal is well known
+func imphash(imports []peImport) string {
+
+	if len(imports) == 0 {
+		return ""
+	}
+
+	names := []string{}
+	for _, imp := range imports {
+		dll := strings.ToLower(imp.DLL)
+		if i := strings.LastIndex(dll, "."); i >= 0 {
+			switch dll[i+1:] {
+			case "dll", "ocx", "sys":
+				dll = dll[:i]
+			}
+		}
+
+		function := imp.Name
+		if len(function) == 0 {
+			function = ordinalName(dll, imp.Ordinal)
+		}
+
+		names = append(names, dll+"."+strings.ToLower(function))
+	}
+
+	sum := md5.Sum([]byte(strings.Join(names, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ordinalName resolves an ordinal import to its function name, or "ordN" when unknown
+func ordinalName(dll string, ordinal uint16) string {
+
+	var table map[uint16]string
+	switch dll {
+	case "ws2_32", "wsock32":
+		table = ws2Ordinals
+	case "oleaut32":
+		table = oleaut32Ordinals
+	}
+
+	if name, ok := table[ordinal]; ok {
+		return name
+	}
+	return fmt.Sprintf("ord%d", ordinal)
+}
+
+// richHeader decodes the Rich header the linker writes between the DOS stub and
+// the PE header, it returns nil when the file has none
+func richHeader(data []byte) *RichHeader {
+
+	if len(data) < 0x40 {
+		return nil
+	}
+	end := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	if end > len(data) || end < 0x80 {
+		end = len(data)
+		if end > 0x1000 {
+			end = 0x1000
+		}
+	}
+
+	// the header ends with "Rich" followed by the key every other dword is xored with
+	rich := -1
+	for i := 0x80; i+8 <= end; i += 4 {
+		if binary.LittleEndian.Uint32(data[i:]) == richMarker {
+			rich = i
+			break
+		}
+	}
+	if rich < 0 {
+		return nil
+	}
+	key := binary.LittleEndian.Uint32(data[rich+4:])
+
+	// walk back to the xored "DanS" marker that starts it
+	start := -1
+	for i := rich - 4; i >= 0x40; i -= 4 {
+		if binary.LittleEndian.Uint32(data[i:])^key == dansMarker {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil
+	}
+
+	clear := make([]byte, rich-start)
+	for i := 0; i < len(clear); i += 4 {
+		binary.LittleEndian.PutUint32(clear[i:], binary.LittleEndian.Uint32(data[start+i:])^key)
+	}
+
+	header := &RichHeader{Key: fmt.Sprintf("0x%08x", key)}
+
+	sum := md5.Sum(clear)
+	header.Hash = hex.EncodeToString(sum[:])
+
+	// "DanS" is followed by three zero dwords of padding, then comp id and count pairs
+	checksum := uint32(start)
+	for i := 0; i < start; i++ {
+		if i >= 0x3c && i < 0x40 {
+			// e_lfanew is not part of the checksum
+			continue
+		}
+		checksum += bits.RotateLeft32(uint32(data[i]), i)
+	}
+
+	for i := 16; i+8 <= len(clear); i += 8 {
+		compID := binary.LittleEndian.Uint32(clear[i:])
+		count := binary.LittleEndian.Uint32(clear[i+4:])
+		header.Entries = append(header.Entries, RichEntry{
+			CompID:  fmt.Sprintf("0x%08x", compID),
+			Product: uint16(compID >> 16),
+			Build:   uint16(compID),
+			Count:   count,
+		})
+		checksum += bits.RotateLeft32(compID, int(count%32))
+	}
+
+	header.Valid = checksum == key
+
+	return header
+}
+
+// ws2Ordinals are the winsock exports commonly imported by ordinal
+var ws2Ordinals = map[uint16]string{
+	1: "accept", 2: "bind", 3: "closesocket", 4: "connect", 5: "getpeername",
+	6: "getsockname", 7: "getsockopt", 8: "htonl", 9: "htons", 10: "ioctlsocket",
+	11: "inet_addr", 12: "inet_ntoa", 13: "listen", 14: "ntohl", 15: "ntohs",
+	16: "recv", 17: "recvfrom", 18: "select", 19: "send", 20: "sendto",
+	21: "setsockopt", 22: "shutdown", 23: "socket",
+	51: "gethostbyaddr", 52: "gethostbyname", 53: "getprotobyname", 54: "getprotobynumber",
+	55: "getservbyname", 56: "getservbyport", 57: "gethostname",
+	101: "WSAAsyncSelect", 102: "WSAAsyncGetHostByAddr", 103: "WSAAsyncGetHostByName",
+	104: "WSAAsyncGetProtoByNumber", 105: "WSAAsyncGetProtoByName", 106: "WSAAsyncGetServByPort",
+	107: "WSAAsyncGetServByName", 108: "WSACancelAsyncRequest", 109: "WSASetBlockingHook",
+	110: "WSAUnhookBlockingHook", 111: "WSAGetLastError", 112: "WSASetLastError",
+	113: "WSACancelBlockingCall", 114: "WSAIsBlocking", 115: "WSAStartup", 116: "WSACleanup",
+	151: "__WSAFDIsSet", 500: "WEP",
+}
+
+// oleaut32Ordinals are the oleaut32 exports commonly imported by ordinal
+var oleaut32Ordinals = map[uint16]string{
+	2: "SysAllocString", 3: "SysReAllocString", 4: "SysAllocStringLen", 5: "SysReAllocStringLen",
+	6: "SysFreeString", 7: "SysStringLen", 8: "VariantInit", 9: "VariantClear",
+	10: "VariantCopy", 11: "VariantCopyInd", 12: "VariantChangeType", 13: "VariantTimeToDosDateTime",
+	14: "DosDateTimeToVariantTime", 15: "SafeArrayCreate", 16: "SafeArrayDestroy", 17: "SafeArrayGetDim",
+	18: "SafeArrayGetElemsize", 19: "SafeArrayGetUBound", 20: "SafeArrayGetLBound", 21: "SafeArrayLock",
+	22: "SafeArrayUnlock", 23: "SafeArrayAccessData", 24: "SafeArrayUnaccessData", 25: "SafeArrayGetElement",
+	26: "SafeArrayPutElement", 27: "SafeArrayCopy", 28: "DispGetParam", 29: "DispGetIDsOfNames",
+	30: "DispInvoke", 31: "CreateDispTypeInfo", 32: "CreateStdDispatch", 33: "RegisterActiveObject",
+	34: "RevokeActiveObject", 35: "GetActiveObject", 36: "SafeArrayAllocDescriptor", 37: "SafeArrayAllocData",
+	38: "SafeArrayDestroyDescriptor", 39: "SafeArrayDestroyData", 40: "SafeArrayRedim",
+	147: "VariantChangeTypeEx", 148: "SafeArrayPtrOfIndex", 149: "SysStringByteLen", 150: "SysAllocStringByteLen",
+	161: "LoadTypeLib", 162: "LoadRegTypeLib", 163: "RegisterTypeLib", 183: "LoadTypeLibEx",
+	200: "GetErrorInfo", 201: "SetErrorInfo", 202: "CreateErrorInfo",
+}
@@ -39,76 +39,164 @@ type ResultsData struct {
 	ProductName       string
 	ProductVersion    string
 	Subsystem         string
-	Sections          []string
+	Sections          []Section
 	Imports           map[string][]string
+	Exports           []Export
+	Resources         []Resource
+	Hashes            Hashes
+	RichHeader        *RichHeader
+	Signature         *Signature
+	Findings          []Finding
+	Verdict           string
+	Engine            string
 	Error             string `json:"error" structs:"error"`
 }
 
+// Section json object
+type Section struct {
+	Name            string   `json:"name" structs:"name"`
+	VirtualAddress  string   `json:"virtual_address" structs:"virtual_address"`
+	VirtualSize     uint64   `json:"virtual_size" structs:"virtual_size"`
+	RawAddress      string   `json:"raw_address" structs:"raw_address"`
+	RawSize         uint64   `json:"raw_size" structs:"raw_size"`
+	Entropy         float64  `json:"entropy" structs:"entropy"`
+	Characteristics []string `json:"characteristics" structs:"characteristics"`
+	MD5             string   `json:"md5,omitempty" structs:"md5"`
+	SHA256          string   `json:"sha256,omitempty" structs:"sha256"`
+}
+
+// Export json object
+type Export struct {
+	Ordinal   uint64 `json:"ordinal" structs:"ordinal"`
+	Name      string `json:"name" structs:"name"`
+	Address   string `json:"address" structs:"address"`
+	Forwarded string `json:"forwarded,omitempty" structs:"forwarded"`
+}
+
+// Resource json object
+type Resource struct {
+	Name     string  `json:"name" structs:"name"`
+	Type     string  `json:"type" structs:"type"`
+	Language string  `json:"language" structs:"language"`
+	Size     uint64  `json:"size" structs:"size"`
+	Entropy  float64 `json:"entropy" structs:"entropy"`
+}
+
+// Hashes json object
+type Hashes struct {
+	MD5     string `json:"md5" structs:"md5"`
+	SHA1    string `json:"sha1" structs:"sha1"`
+	SHA256  string `json:"sha256" structs:"sha256"`
+	SHA3    string `json:"sha3" structs:"sha3"`
+	SSDeep  string `json:"ssdeep" structs:"ssdeep"`
+	Imphash string `json:"imphash" structs:"imphash"`
+}
+
 // AvScan performs antivirus scan
-func AvScan(timeout int) Manalyze {
+func AvScan(timeout int, plugins bool, engine string, trustStore string) Manalyze {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
 	defer cancel()
 
-	results, err := utils.RunCommand(ctx, "/opt/Manalyze/bin/manalyze", "--output=json", "--dump=summary,sections,imports", path)
-	if err != nil {
-		log.Debug(errors.Wrap(err, "Error while running manalyze command"))
+	var results ResultsData
+
+	if engine == "go" {
+		results = runPE(ctx)
+	} else {
+		results = runManalyze(ctx, plugins)
+
+		// fall back to parsing the file ourselves when manalyze fails, times out or rejects it
+		if engine == "auto" && (results.Error != "nil" || len(results.Architecture) == 0) {
+			log.Debugf("manalyze failed (%s), falling back to the go pe parser", results.Error)
+			if fallback := runPE(ctx); fallback.Error == "nil" {
+				results = fallback
+			}
+		}
+	}
+
+	if results.Error == "nil" {
+		results.Signature = authenticode(path, trustStore)
 	}
 
 	return Manalyze{
-		Results: ParseOutput([]byte(results), err),
+		Results: results,
 	}
 }
 
-// ParseOutput converts manalyze output into a manalyze struct
-func ParseOutput(manalyzeout []byte, err error) ResultsData {
+// runPE runs the go pe parser on the file, giving up when ctx is done
+func runPE(ctx context.Context) ResultsData {
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		// debug/pe panics on some malformed files
+		defer func() {
+			if r := recover(); r != nil {
+				done <- ResultsData{Engine: "go", Error: fmt.Sprint(r)}
+			}
+		}()
+		done <- analyzePE(path)
+	}()
+
+	select {
+	case results := <-done:
+		return results
+	case <-ctx.Done():
+		return ResultsData{Engine: "go", Error: fmt.Sprintf("analysis of %s timed out", path)}
+	}
+}
 
-	manalyzeResult := ResultsData{Error: "nil"}
+// runManalyze runs manalyze on the file and adds the hashes it does not compute
+func runManalyze(ctx context.Context, plugins bool) ResultsData {
 
-	if err != nil {
-		manalyzeResult.Error = err.Error()
-		return manalyzeResult
+	args := []string{"--output=json", "--dump=all", "--hashes"}
+	if plugins {
+		args = append(args, "--plugins=all")
 	}
 
-	var summary map[string]map[string]map[string]string
-	var sections map[string]map[string]map[string]interface{}
-	var imports map[string]map[string]map[string][]string
-
-	err = json.Unmarshal(manalyzeout, &summary)
+	output, err := utils.RunCommand(ctx, "/opt/Manalyze/bin/manalyze", append(args, path)...)
 	if err != nil {
-		log.Debug(errors.Wrap(err, "Error while unmarshaling summary manalyze output"))
+		log.Debug(errors.Wrap(err, "Error while running manalyze command"))
 	}
-	err = json.Unmarshal(manalyzeout, &sections)
-	if err != nil {
-		log.Debug(errors.Wrap(err, "Error while unmarshaling sections manalyze output"))
+
+	results := ParseOutput([]byte(output), err)
+	if results.Error == "nil" {
+		addPEHashes(path, &results)
 	}
-	err = json.Unmarshal(manalyzeout, &imports)
+
+	return results
+}
+
+// ParseOutput converts manalyze output into a manalyze struct
+func ParseOutput(manalyzeout []byte, err error) ResultsData {
+
+	manalyzeResult := ResultsData{Engine: "manalyze", Error: "nil"}
+
 	if err != nil {
-		log.Debug(errors.Wrap(err, "Error while unmarshaling imports manalyze output"))
+		manalyzeResult.Error = err.Error()
+		return manalyzeResult
 	}
 
-	manalyzeResult.Architecture = summary[path]["Summary"]["Architecture"]
-	manalyzeResult.CompilationDate = summary[path]["Summary"]["Compilation Date"]
-	manalyzeResult.DetectedLanguages = summary[path]["Summary"]["Detected languages"]
-	manalyzeResult.FileVersion = summary[path]["Summary"]["FileVersion"]
-	manalyzeResult.InternalName = summary[path]["Summary"]["InternalName"]
-	manalyzeResult.OriginalFilename = summary[path]["Summary"]["OriginalFilename"]
-	manalyzeResult.ProductName = summary[path]["Summary"]["ProductName"]
-	manalyzeResult.ProductVersion = summary[path]["Summary"]["ProductVersion"]
-	manalyzeResult.Subsystem = summary[path]["Summary"]["Subsystem"]
-
-	for k := range sections[path]["Sections"] {
+	// manalyze keys its report by the path it was given
+	var reports map[string]manalyzeReport
 
-		manalyzeResult.Sections = append(manalyzeResult.Sections, k)
+	err = json.Unmarshal(manalyzeout, &reports)
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		// the decoder skips fields of an unexpected type and fills in the rest
+		log.Debug(errors.Wrap(err, "Error while unmarshaling part of manalyze output"))
+	} else if err != nil {
+		log.Debug(errors.Wrap(err, "Error while unmarshaling manalyze output"))
+		manalyzeResult.Error = err.Error()
+		return manalyzeResult
 	}
 
-	for k, v := range imports[path]["Imports"] {
-		log.Debug(k, v)
-		temp := make(map[string][]string)
-		temp[k] = v
-		manalyzeResult.Imports = temp
+	report, ok := reports[path]
+	if !ok {
+		for _, r := range reports {
+			report = r
+			break
+		}
 	}
 
-	return manalyzeResult
+	return resultsFromReport(report)
 }
 
 func main() {
@@ -130,6 +218,23 @@ func main() {
 			Usage:  "malcan plugin timeout (in seconds)",
 			EnvVar: "MALSCAN_TIMEOUT",
 		},
+		cli.BoolFlag{
+			Name:   "plugins",
+			Usage:  "run all of manalyze's analysis plugins and report their findings",
+			EnvVar: "MALSCAN_MANALYZE_PLUGINS",
+		},
+		cli.StringFlag{
+			Name:   "engine",
+			Value:  "auto",
+			Usage:  "pe parser to use: manalyze, go, or auto to fall back to go when manalyze fails",
+			EnvVar: "MALSCAN_MANALYZE_ENGINE",
+		},
+		cli.StringFlag{
+			Name:   "trust-store",
+			Value:  "/etc/malscan/trust",
+			Usage:  "file or directory of trusted root certificates (pem or der) for authenticode verification",
+			EnvVar: "MALSCAN_MANALYZE_TRUST_STORE",
+		},
 	}
 	app.Action = func(c *cli.Context) error {
 
@@ -141,7 +246,12 @@ func main() {
 
 			path, _ = filepath.Abs(c.Args().First())
 
-			manalyze := AvScan(c.Int("timeout"))
+			engine := c.String("engine")
+			if engine != "auto" && engine != "manalyze" && engine != "go" {
+				return errors.Errorf("unknown engine %q", engine)
+			}
+
+			manalyze := AvScan(c.Int("timeout"), c.Bool("plugins"), engine, c.String("trust-store"))
 
 			// convert to JSON
 			manalyzeJSON, _ := json.Marshal(manalyze)
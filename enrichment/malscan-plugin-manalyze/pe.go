@@ -0,0 +1,822 @@
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"debug/pe"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// limits on the tables walked in a pe file, malformed files can claim anything
+const (
+	maxImports   = 4096
+	maxThunks    = 65536
+	maxExports   = 65536
+	maxResources = 4096
+	// maxResourceEntries bounds the directory entries read across the whole
+	// resource tree, whose levels can all point at the same directories
+	maxResourceEntries = 16384
+)
+
+// data directory indexes, debug/pe only defines these from go 1.15
+const (
+	directoryExport   = 0
+	directoryImport   = 1
+	directoryResource = 2
+)
+
+// peImage is a pe file parsed with debug/pe along with its raw bytes, which the
+// tables debug/pe does not read are parsed from
+type peImage struct {
+	file *pe.File
+	data []byte
+}
+
+// peImport is one imported function, functions imported by ordinal have no name
+type peImport struct {
+	DLL     string
+	Name    string
+	Ordinal uint16
+}
+
+// analyzePE reads the file with debug/pe and fills the same fields as a manalyze report
+func analyzePE(file string) ResultsData {
+
+	results := ResultsData{Engine: "go", Error: "nil"}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		results.Error = err.Error()
+		return results
+	}
+
+	image, err := openPE(data)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing pe file"))
+		results.Error = err.Error()
+		return results
+	}
+	defer image.file.Close()
+
+	results.Architecture = machineName(image.file.Machine)
+	results.CompilationDate = time.Unix(int64(image.file.TimeDateStamp), 0).UTC().Format("2006-Jan-02 15:04:05")
+	results.Subsystem = subsystemName(image.subsystem())
+	results.Sections = image.sections()
+
+	imports := image.imports()
+	if len(imports) != 0 {
+		results.Imports = map[string][]string{}
+	}
+	for _, imp := range imports {
+		name := imp.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("#%d", imp.Ordinal)
+		}
+		results.Imports[imp.DLL] = append(results.Imports[imp.DLL], name)
+	}
+
+	results.Exports = image.exports()
+
+	var version []byte
+	results.Resources, version = image.resources()
+
+	languages := []string{}
+	seen := map[string]bool{}
+	for _, resource := range results.Resources {
+		if len(resource.Language) != 0 && !seen[resource.Language] {
+			seen[resource.Language] = true
+			languages = append(languages, resource.Language)
+		}
+	}
+	sort.Strings(languages)
+	results.DetectedLanguages = strings.Join(languages, ", ")
+
+	info := versionStrings(version)
+	results.FileVersion = info["FileVersion"]
+	results.InternalName = info["InternalName"]
+	results.OriginalFilename = info["OriginalFilename"]
+	results.ProductName = info["ProductName"]
+	results.ProductVersion = info["ProductVersion"]
+
+	md5sum := md5.Sum(data)
+	sha1sum := sha1.Sum(data)
+	sha256sum := sha256.Sum256(data)
+	results.Hashes = Hashes{
+		MD5:    hex.EncodeToString(md5sum[:]),
+		SHA1:   hex.EncodeToString(sha1sum[:]),
+		SHA256: hex.EncodeToString(sha256sum[:]),
+	}
+
+	hashPE(image, &results)
+
+	return results
+}
+
+// openPE parses data with debug/pe. debug/pe rejects files whose COFF symbol table
+// is out of bounds, which executables never need, so those are retried without it
+func openPE(data []byte) (*peImage, error) {
+
+	file, err := pe.NewFile(bytes.NewReader(data))
+	if err == nil {
+		return &peImage{file: file, data: data}, nil
+	}
+
+	if len(data) < 0x40 {
+		return nil, err
+	}
+	header := int(binary.LittleEndian.Uint32(data[0x3c:]))
+	if header < 0 || header+20 > len(data) || binary.LittleEndian.Uint32(data[header+12:]) == 0 {
+		return nil, err
+	}
+
+	// zero PointerToSymbolTable and NumberOfSymbols in a copy of the file
+	patched := make([]byte, len(data))
+	copy(patched, data)
+	for i := header + 12; i < header+20; i++ {
+		patched[i] = 0
+	}
+
+	file, retryErr := pe.NewFile(bytes.NewReader(patched))
+	if retryErr != nil {
+		return nil, err
+	}
+	return &peImage{file: file, data: data}, nil
+}
+
+func (p *peImage) subsystem() uint16 {
+	switch header := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return header.Subsystem
+	case *pe.OptionalHeader64:
+		return header.Subsystem
+	}
+	return 0
+}
+
+func (p *peImage) is64() bool {
+	_, ok := p.file.OptionalHeader.(*pe.OptionalHeader64)
+	return ok
+}
+
+// directory returns one of the optional header's data directories
+func (p *peImage) directory(index int) pe.DataDirectory {
+	switch header := p.file.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if index < int(header.NumberOfRvaAndSizes) && index < len(header.DataDirectory) {
+			return header.DataDirectory[index]
+		}
+	case *pe.OptionalHeader64:
+		if index < int(header.NumberOfRvaAndSizes) && index < len(header.DataDirectory) {
+			return header.DataDirectory[index]
+		}
+	}
+	return pe.DataDirectory{}
+}
+
+// offset converts a relative virtual address into a file offset
+func (p *peImage) offset(rva uint32) (int, bool) {
+
+	for _, section := range p.file.Sections {
+		size := section.VirtualSize
+		if section.Size > size {
+			size = section.Size
+		}
+		if rva >= section.VirtualAddress && rva-section.VirtualAddress < size {
+			delta := rva - section.VirtualAddress
+			if delta >= section.Size {
+				// the address lies in the zero filled tail of the section
+				return 0, false
+			}
+			offset := int(section.Offset) + int(delta)
+			return offset, offset < len(p.data)
+		}
+	}
+
+	// addresses before the first section point into the headers
+	if len(p.file.Sections) == 0 || rva < p.file.Sections[0].VirtualAddress {
+		return int(rva), int(rva) < len(p.data)
+	}
+
+	return 0, false
+}
+
+// bytesAt returns up to n bytes at rva
+func (p *peImage) bytesAt(rva uint32, n int) []byte {
+	offset, ok := p.offset(rva)
+	if !ok || n < 0 {
+		return nil
+	}
+	end := offset + n
+	if end > len(p.data) || end < offset {
+		end = len(p.data)
+	}
+	return p.data[offset:end]
+}
+
+func (p *peImage) uint16At(rva uint32) (uint16, bool) {
+	b := p.bytesAt(rva, 2)
+	if len(b) < 2 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(b), true
+}
+
+func (p *peImage) uint32At(rva uint32) (uint32, bool) {
+	b := p.bytesAt(rva, 4)
+	if len(b) < 4 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint32(b), true
+}
+
+func (p *peImage) uint64At(rva uint32) (uint64, bool) {
+	b := p.bytesAt(rva, 8)
+	if len(b) < 8 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(b), true
+}
+
+// stringAt returns the null terminated string at rva
+func (p *peImage) stringAt(rva uint32) string {
+	b := p.bytesAt(rva, 512)
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// sections lists the section table with the entropy of each section's raw data
+func (p *peImage) sections() []Section {
+
+	var sections []Section
+
+	for _, section := range p.file.Sections {
+		sections = append(sections, Section{
+			Name:            section.Name,
+			VirtualAddress:  hexNumber(uint64(section.VirtualAddress)),
+			VirtualSize:     uint64(section.VirtualSize),
+			RawAddress:      hexNumber(uint64(section.Offset)),
+			RawSize:         uint64(section.Size),
+			Entropy:         entropy(p.raw(section)),
+			Characteristics: sectionCharacteristics(section.Characteristics),
+		})
+	}
+
+	return sections
+}
+
+// raw returns the part of a section's raw data present in the file
+func (p *peImage) raw(section *pe.Section) []byte {
+	start := int(section.Offset)
+	end := start + int(section.Size)
+	if start > len(p.data) {
+		return nil
+	}
+	if end > len(p.data) || end < start {
+		end = len(p.data)
+	}
+	return p.data[start:end]
+}
+
+// imports walks the import directory, unlike debug/pe's ImportedSymbols it
+// keeps functions imported by ordinal
+func (p *peImage) imports() []peImport {
+
+	var imports []peImport
+
+	dir := p.directory(directoryImport)
+	if dir.VirtualAddress == 0 {
+		return nil
+	}
+
+	for i := uint32(0); i < maxImports; i++ {
+		descriptor := p.bytesAt(dir.VirtualAddress+i*20, 20)
+		if len(descriptor) < 20 {
+			break
+		}
+		lookup := binary.LittleEndian.Uint32(descriptor[0:])
+		nameRVA := binary.LittleEndian.Uint32(descriptor[12:])
+		thunks := binary.LittleEndian.Uint32(descriptor[16:])
+		if nameRVA == 0 && thunks == 0 {
+			break
+		}
+		if lookup == 0 {
+			lookup = thunks
+		}
+
+		dll := p.stringAt(nameRVA)
+		if len(dll) == 0 {
+			continue
+		}
+
+		for j := uint32(0); j < maxThunks; j++ {
+			var ordinal bool
+			var value uint64
+
+			if p.is64() {
+				thunk, ok := p.uint64At(lookup + j*8)
+				if !ok || thunk == 0 {
+					break
+				}
+				ordinal, value = thunk&(1<<63) != 0, thunk
+			} else {
+				thunk, ok := p.uint32At(lookup + j*4)
+				if !ok || thunk == 0 {
+					break
+				}
+				ordinal, value = thunk&(1<<31) != 0, uint64(thunk)
+			}
+
+			if ordinal {
+				imports = append(imports, peImport{DLL: dll, Ordinal: uint16(value)})
+				continue
+			}
+
+			// the hint name entry is a two byte hint followed by the name
+			name := p.stringAt(uint32(value) + 2)
+			if len(name) != 0 {
+				imports = append(imports, peImport{DLL: dll, Name: name})
+			}
+		}
+	}
+
+	return imports
+}
+
+// exports walks the export directory, reporting named and ordinal only exports
+func (p *peImage) exports() []Export {
+
+	var exports []Export
+
+	dir := p.directory(directoryExport)
+	if dir.VirtualAddress == 0 {
+		return nil
+	}
+
+	header := p.bytesAt(dir.VirtualAddress, 40)
+	if len(header) < 40 {
+		return nil
+	}
+	base := binary.LittleEndian.Uint32(header[16:])
+	functions := binary.LittleEndian.Uint32(header[20:])
+	names := binary.LittleEndian.Uint32(header[24:])
+	addresses := binary.LittleEndian.Uint32(header[28:])
+	nameTable := binary.LittleEndian.Uint32(header[32:])
+	ordinalTable := binary.LittleEndian.Uint32(header[36:])
+
+	if functions > maxExports {
+		functions = maxExports
+	}
+	if names > functions {
+		names = functions
+	}
+
+	exported := map[uint32]string{}
+	for i := uint32(0); i < names; i++ {
+		nameRVA, ok := p.uint32At(nameTable + i*4)
+		if !ok {
+			break
+		}
+		index, ok := p.uint16At(ordinalTable + i*2)
+		if !ok {
+			break
+		}
+		exported[uint32(index)] = p.stringAt(nameRVA)
+	}
+
+	for i := uint32(0); i < functions; i++ {
+		address, ok := p.uint32At(addresses + i*4)
+		if !ok {
+			break
+		}
+		if address == 0 {
+			continue
+		}
+
+		export := Export{
+			Ordinal: uint64(base + i),
+			Name:    exported[i],
+			Address: hexNumber(uint64(address)),
+		}
+
+		// addresses inside the export directory point to a forwarder string
+		if address >= dir.VirtualAddress && address < dir.VirtualAddress+dir.Size {
+			export.Forwarded = p.stringAt(address)
+		}
+
+		exports = append(exports, export)
+	}
+
+	return exports
+}
+
+// resources walks the type, name and language levels of the resource directory,
+// returning the resources and the data of the first version resource
+func (p *peImage) resources() ([]Resource, []byte) {
+
+	var resources []Resource
+	var version []byte
+
+	dir := p.directory(directoryResource)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	root := dir.VirtualAddress
+	budget := maxResourceEntries
+
+walk:
+	for _, typ := range p.resourceEntries(root, root, &budget) {
+		if budget <= 0 {
+			break
+		}
+		if !typ.directory {
+			continue
+		}
+		typeName := typ.name
+		if len(typeName) == 0 {
+			typeName = resourceTypes[typ.id]
+		}
+		if len(typeName) == 0 {
+			typeName = fmt.Sprint(typ.id)
+		}
+
+		for _, named := range p.resourceEntries(root, root+typ.offset, &budget) {
+			if budget <= 0 {
+				break walk
+			}
+			if !named.directory {
+				continue
+			}
+			name := named.name
+			if len(name) == 0 {
+				name = fmt.Sprint(named.id)
+			}
+
+			for _, lang := range p.resourceEntries(root, root+named.offset, &budget) {
+				if len(resources) >= maxResources {
+					break walk
+				}
+				if lang.directory {
+					continue
+				}
+				entry := p.bytesAt(root+lang.offset, 16)
+				if len(entry) < 16 {
+					continue
+				}
+				dataRVA := binary.LittleEndian.Uint32(entry[0:])
+				size := binary.LittleEndian.Uint32(entry[4:])
+				data := p.bytesAt(dataRVA, int(size))
+
+				resources = append(resources, Resource{
+					Name:     typeName + "/" + name,
+					Type:     typeName,
+					Language: languageName(lang.id),
+					Size:     uint64(size),
+					Entropy:  entropy(data),
+				})
+
+				if typ.id == 16 && len(typ.name) == 0 && version == nil {
+					version = data
+				}
+			}
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Name < resources[j].Name
+	})
+
+	return resources, version
+}
+
+type resourceEntry struct {
+	id        uint32
+	name      string
+	offset    uint32
+	directory bool
+}
+
+// resourceEntries reads the entries of the resource directory at rva, offsets are
+// relative to the start of the resource section, each entry read is taken from budget
+func (p *peImage) resourceEntries(root uint32, rva uint32, budget *int) []resourceEntry {
+
+	var entries []resourceEntry
+
+	if *budget <= 0 {
+		return nil
+	}
+
+	header := p.bytesAt(rva, 16)
+	if len(header) < 16 {
+		return nil
+	}
+	count := uint32(binary.LittleEndian.Uint16(header[12:])) + uint32(binary.LittleEndian.Uint16(header[14:]))
+	if count > maxResources {
+		count = maxResources
+	}
+
+	for i := uint32(0); i < count && *budget > 0; i++ {
+		*budget--
+		raw := p.bytesAt(rva+16+i*8, 8)
+		if len(raw) < 8 {
+			break
+		}
+		id := binary.LittleEndian.Uint32(raw[0:])
+		offset := binary.LittleEndian.Uint32(raw[4:])
+
+		entry := resourceEntry{
+			id:        id,
+			offset:    offset &^ (1 << 31),
+			directory: offset&(1<<31) != 0,
+		}
+
+		// directories nested in themselves would never end
+		if entry.directory && root+entry.offset <= rva {
+			continue
+		}
+
+		if id&(1<<31) != 0 {
+			// named entries point to a length prefixed utf-16 string
+			nameRVA := root + id&^(1<<31)
+			if length, ok := p.uint16At(nameRVA); ok {
+				entry.name = decodeUTF16(p.bytesAt(nameRVA+2, int(length)*2))
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+// versionStrings reads the StringFileInfo values from a VS_VERSIONINFO resource
+func versionStrings(data []byte) map[string]string {
+
+	info := map[string]string{}
+
+	key, _, children := versionBlock(data)
+	if key != "VS_VERSION_INFO" {
+		return info
+	}
+
+	for _, child := range children {
+		key, _, tables := versionBlock(child)
+		if key != "StringFileInfo" {
+			continue
+		}
+		for _, table := range tables {
+			_, _, strs := versionBlock(table)
+			for _, str := range strs {
+				name, value, _ := versionBlock(str)
+				if _, ok := info[name]; !ok && len(name) != 0 {
+					info[name] = strings.TrimRight(decodeUTF16(value), "\x00")
+				}
+			}
+		}
+	}
+
+	return info
+}
+
+// versionBlock splits one block of a version resource into its key, value and children
+func versionBlock(data []byte) (string, []byte, [][]byte) {
+
+	if len(data) < 6 {
+		return "", nil, nil
+	}
+	length := int(binary.LittleEndian.Uint16(data[0:]))
+	valueLength := int(binary.LittleEndian.Uint16(data[2:]))
+	text := binary.LittleEndian.Uint16(data[4:]) == 1
+	if length > len(data) || length < 6 {
+		length = len(data)
+	}
+	data = data[:length]
+
+	pos := 6
+	for pos+1 < len(data) && (data[pos] != 0 || data[pos+1] != 0) {
+		pos += 2
+	}
+	key := decodeUTF16(data[6:pos])
+	pos = align4(pos + 2)
+
+	// text values are measured in utf-16 characters, binary values in bytes
+	if text {
+		valueLength *= 2
+	}
+	var value []byte
+	if pos < len(data) {
+		end := pos + valueLength
+		if end > len(data) {
+			end = len(data)
+		}
+		value = data[pos:end]
+	}
+	pos = align4(pos + valueLength)
+
+	var children [][]byte
+	for pos+6 <= len(data) {
+		childLength := int(binary.LittleEndian.Uint16(data[pos:]))
+		if childLength == 0 {
+			break
+		}
+		end := pos + childLength
+		if end > len(data) {
+			end = len(data)
+		}
+		children = append(children, data[pos:end])
+		pos = align4(end)
+	}
+
+	return key, value, children
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+func decodeUTF16(data []byte) string {
+	chars := make([]uint16, len(data)/2)
+	for i := range chars {
+		chars[i] = binary.LittleEndian.Uint16(data[i*2:])
+	}
+	return string(utf16.Decode(chars))
+}
+
+// entropy returns the shannon entropy of data in bits per byte
+func entropy(data []byte) float64 {
+
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]float64
+	for _, b := range data {
+		counts[b]++
+	}
+
+	total := float64(len(data))
+	result := 0.0
+	for _, count := range counts {
+		if count != 0 {
+			p := count / total
+			result -= p * math.Log2(p)
+		}
+	}
+
+	return math.Round(result*1000) / 1000
+}
+
+func machineName(machine uint16) string {
+	if name, ok := machines[machine]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", machine)
+}
+
+func subsystemName(subsystem uint16) string {
+	if name, ok := subsystems[subsystem]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", subsystem)
+}
+
+func sectionCharacteristics(characteristics uint32) []string {
+	names := []string{}
+	for _, flag := range sectionFlags {
+		if characteristics&flag.value != 0 {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+func languageName(id uint32) string {
+	if name, ok := languages[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", id)
+}
+
+var machines = map[uint16]string{
+	0x014c: "IMAGE_FILE_MACHINE_I386",
+	0x8664: "IMAGE_FILE_MACHINE_AMD64",
+	0x01c0: "IMAGE_FILE_MACHINE_ARM",
+	0x01c2: "IMAGE_FILE_MACHINE_THUMB",
+	0x01c4: "IMAGE_FILE_MACHINE_ARMNT",
+	0xaa64: "IMAGE_FILE_MACHINE_ARM64",
+	0x0200: "IMAGE_FILE_MACHINE_IA64",
+	0x0ebc: "IMAGE_FILE_MACHINE_EBC",
+}
+
+var subsystems = map[uint16]string{
+	1:  "IMAGE_SUBSYSTEM_NATIVE",
+	2:  "IMAGE_SUBSYSTEM_WINDOWS_GUI",
+	3:  "IMAGE_SUBSYSTEM_WINDOWS_CUI",
+	5:  "IMAGE_SUBSYSTEM_OS2_CUI",
+	7:  "IMAGE_SUBSYSTEM_POSIX_CUI",
+	9:  "IMAGE_SUBSYSTEM_WINDOWS_CE_GUI",
+	10: "IMAGE_SUBSYSTEM_EFI_APPLICATION",
+	11: "IMAGE_SUBSYSTEM_EFI_BOOT_SERVICE_DRIVER",
+	12: "IMAGE_SUBSYSTEM_EFI_RUNTIME_DRIVER",
+	13: "IMAGE_SUBSYSTEM_EFI_ROM",
+	14: "IMAGE_SUBSYSTEM_XBOX",
+	16: "IMAGE_SUBSYSTEM_WINDOWS_BOOT_APPLICATION",
+}
+
+var sectionFlags = []struct {
+	value uint32
+	name  string
+}{
+	{0x00000020, "IMAGE_SCN_CNT_CODE"},
+	{0x00000040, "IMAGE_SCN_CNT_INITIALIZED_DATA"},
+	{0x00000080, "IMAGE_SCN_CNT_UNINITIALIZED_DATA"},
+	{0x00000200, "IMAGE_SCN_LNK_INFO"},
+	{0x00000800, "IMAGE_SCN_LNK_REMOVE"},
+	{0x00001000, "IMAGE_SCN_LNK_COMDAT"},
+	{0x00008000, "IMAGE_SCN_GPREL"},
+	{0x01000000, "IMAGE_SCN_LNK_NRELOC_OVFL"},
+	{0x02000000, "IMAGE_SCN_MEM_DISCARDABLE"},
+	{0x04000000, "IMAGE_SCN_MEM_NOT_CACHED"},
+	{0x08000000, "IMAGE_SCN_MEM_NOT_PAGED"},
+	{0x10000000, "IMAGE_SCN_MEM_SHARED"},
+	{0x20000000, "IMAGE_SCN_MEM_EXECUTE"},
+	{0x40000000, "IMAGE_SCN_MEM_READ"},
+	{0x80000000, "IMAGE_SCN_MEM_WRITE"},
+}
+
+var resourceTypes = map[uint32]string{
+	1:  "RT_CURSOR",
+	2:  "RT_BITMAP",
+	3:  "RT_ICON",
+	4:  "RT_MENU",
+	5:  "RT_DIALOG",
+	6:  "RT_STRING",
+	7:  "RT_FONTDIR",
+	8:  "RT_FONT",
+	9:  "RT_ACCELERATOR",
+	10: "RT_RCDATA",
+	11: "RT_MESSAGETABLE",
+	12: "RT_GROUP_CURSOR",
+	14: "RT_GROUP_ICON",
+	16: "RT_VERSION",
+	17: "RT_DLGINCLUDE",
+	19: "RT_PLUGPLAY",
+	20: "RT_VXD",
+	21: "RT_ANICURSOR",
+	22: "RT_ANIICON",
+	23: "RT_HTML",
+	24: "RT_MANIFEST",
+}
+
+// languages maps the most common resource language ids to their names
+var languages = map[uint32]string{
+	0x0000: "Neutral",
+	0x0400: "Process default",
+	0x0800: "System default",
+	0x0401: "Arabic - Saudi Arabia",
+	0x0404: "Chinese - Taiwan",
+	0x0405: "Czech",
+	0x0406: "Danish",
+	0x0407: "German - Germany",
+	0x0408: "Greek",
+	0x0409: "English - United States",
+	0x040a: "Spanish - Traditional Sort",
+	0x040b: "Finnish",
+	0x040c: "French - France",
+	0x040d: "Hebrew",
+	0x040e: "Hungarian",
+	0x0410: "Italian - Italy",
+	0x0411: "Japanese",
+	0x0412: "Korean",
+	0x0413: "Dutch - Netherlands",
+	0x0414: "Norwegian - Bokmal",
+	0x0415: "Polish",
+	0x0416: "Portuguese - Brazil",
+	0x0418: "Romanian",
+	0x0419: "Russian",
+	0x041d: "Swedish",
+	0x041e: "Thai",
+	0x041f: "Turkish",
+	0x0421: "Indonesian",
+	0x0422: "Ukrainian",
+	0x0429: "Farsi",
+	0x042a: "Vietnamese",
+	0x0804: "Chinese - PRC",
+	0x0809: "English - United Kingdom",
+	0x0816: "Portuguese - Portugal",
+	0x0c0a: "Spanish - Modern Sort",
+}
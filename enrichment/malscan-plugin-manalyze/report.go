@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// manalyzeReport is the part of manalyze's json output (--dump=all --hashes) the plugin reads
+type manalyzeReport struct {
+	Summary   map[string]interface{}      `json:"Summary"`
+	Sections  map[string]manalyzeSection  `json:"Sections"`
+	Imports   map[string][]string         `json:"Imports"`
+	Exports   []manalyzeExport            `json:"Exports"`
+	Resources map[string]manalyzeResource `json:"Resources"`
+	Hashes    map[string]string           `json:"Hashes"`
+	Plugins   map[string]manalyzePlugin   `json:"Plugins"`
+}
+
+type manalyzeSection struct {
+	VirtualAddress   manalyzeNumber `json:"VirtualAddress"`
+	VirtualSize      manalyzeNumber `json:"VirtualSize"`
+	SizeOfRawData    manalyzeNumber `json:"SizeOfRawData"`
+	PointerToRawData manalyzeNumber `json:"PointerToRawData"`
+	Characteristics  []string       `json:"Characteristics"`
+	Entropy          float64        `json:"Entropy"`
+}
+
+type manalyzeExport struct {
+	Ordinal     manalyzeNumber `json:"Ordinal"`
+	Name        string         `json:"Name"`
+	Address     manalyzeNumber `json:"Address"`
+	ForwardName string         `json:"ForwardName"`
+}
+
+type manalyzeResource struct {
+	Type     string         `json:"Type"`
+	Language string         `json:"Language"`
+	Size     manalyzeNumber `json:"Size"`
+	Entropy  float64        `json:"Entropy"`
+}
+
+// manalyzeNumber reads the numbers manalyze writes either as json numbers or hex strings
+type manalyzeNumber uint64
+
+func (n *manalyzeNumber) UnmarshalJSON(data []byte) error {
+
+	var value uint64
+	if err := json.Unmarshal(data, &value); err == nil {
+		*n = manalyzeNumber(value)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	// values that are not numbers are left as zero rather than failing the whole report
+	if value, err := strconv.ParseUint(strings.TrimSpace(str), 0, 64); err == nil {
+		*n = manalyzeNumber(value)
+	}
+
+	return nil
+}
+
+// summaryValue returns a summary entry as a string, joining the entries manalyze writes as lists
+func (r manalyzeReport) summaryValue(key string) string {
+
+	switch value := r.Summary[key].(type) {
+	case string:
+		return value
+	case []interface{}:
+		values := []string{}
+		for _, v := range value {
+			values = append(values, fmt.Sprint(v))
+		}
+		return strings.Join(values, ", ")
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func hexNumber(n uint64) string {
+	return fmt.Sprintf("0x%x", n)
+}
+
+// resultsFromReport converts a manalyze report into ResultsData
+func resultsFromReport(report manalyzeReport) ResultsData {
+
+	results := ResultsData{Engine: "manalyze", Error: "nil"}
+
+	results.Architecture = report.summaryValue("Architecture")
+	results.CompilationDate = report.summaryValue("Compilation Date")
+	results.DetectedLanguages = report.summaryValue("Detected languages")
+	results.FileVersion = report.summaryValue("FileVersion")
+	results.InternalName = report.summaryValue("InternalName")
+	results.OriginalFilename = report.summaryValue("OriginalFilename")
+	results.ProductName = report.summaryValue("ProductName")
+	results.ProductVersion = report.summaryValue("ProductVersion")
+	results.Subsystem = report.summaryValue("Subsystem")
+
+	names := []string{}
+	for name := range report.Sections {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return report.Sections[names[i]].VirtualAddress < report.Sections[names[j]].VirtualAddress
+	})
+
+	for _, name := range names {
+		section := report.Sections[name]
+		results.Sections = append(results.Sections, Section{
+			Name:            name,
+			VirtualAddress:  hexNumber(uint64(section.VirtualAddress)),
+			VirtualSize:     uint64(section.VirtualSize),
+			RawAddress:      hexNumber(uint64(section.PointerToRawData)),
+			RawSize:         uint64(section.SizeOfRawData),
+			Entropy:         section.Entropy,
+			Characteristics: section.Characteristics,
+		})
+	}
+
+	results.Imports = report.Imports
+
+	for _, export := range report.Exports {
+		results.Exports = append(results.Exports, Export{
+			Ordinal:   uint64(export.Ordinal),
+			Name:      export.Name,
+			Address:   hexNumber(uint64(export.Address)),
+			Forwarded: export.ForwardName,
+		})
+	}
+
+	for name, resource := range report.Resources {
+		results.Resources = append(results.Resources, Resource{
+			Name:     name,
+			Type:     resource.Type,
+			Language: resource.Language,
+			Size:     uint64(resource.Size),
+			Entropy:  resource.Entropy,
+		})
+	}
+	sort.Slice(results.Resources, func(i, j int) bool {
+		return results.Resources[i].Name < results.Resources[j].Name
+	})
+
+	results.Hashes = Hashes{
+		MD5:     report.Hashes["MD5"],
+		SHA1:    report.Hashes["SHA1"],
+		SHA256:  report.Hashes["SHA256"],
+		SHA3:    report.Hashes["SHA3"],
+		SSDeep:  report.Hashes["SSDeep"],
+		Imphash: report.Hashes["Imports"],
+	}
+
+	if report.Plugins != nil {
+		results.Findings, results.Verdict = findingsFromPlugins(report.Plugins)
+	}
+
+	return results
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxDepth limits how far embedded documents are followed
+const maxDepth = 4
+
+// excel biff records
+const (
+	biffName       = 0x0018
+	biffFilePass   = 0x002f
+	biffBoundSheet = 0x0085
+)
+
+// Stream json object, a storage or stream of a compound file
+type Stream struct {
+	// Container is the package part or object holding the compound file, empty
+	// for the scanned file itself
+	Container string `json:"container,omitempty" structs:"container,omitempty"`
+	Path      string `json:"path" structs:"path"`
+	Type      string `json:"type" structs:"type"`
+	Size      uint64 `json:"size" structs:"size"`
+	CLSID     string `json:"clsid,omitempty" structs:"clsid,omitempty"`
+}
+
+// Object json object, an embedded ole object, packaged file or document
+type Object struct {
+	Container  string `json:"container,omitempty" structs:"container,omitempty"`
+	Name       string `json:"name" structs:"name"`
+	Type       string `json:"type" structs:"type"`
+	CLSID      string `json:"clsid,omitempty" structs:"clsid,omitempty"`
+	Class      string `json:"class,omitempty" structs:"class,omitempty"`
+	Size       uint64 `json:"size" structs:"size"`
+	FileName   string `json:"file_name,omitempty" structs:"file_name,omitempty"`
+	SourcePath string `json:"source_path,omitempty" structs:"source_path,omitempty"`
+	TempPath   string `json:"temp_path,omitempty" structs:"temp_path,omitempty"`
+	SHA256     string `json:"sha256,omitempty" structs:"sha256,omitempty"`
+}
+
+type oleClass struct {
+	name string
+	// exploit is set for classes that are mostly embedded to exploit them
+	exploit string
+}
+
+// oleClasses names the object classes seen in malicious documents
+var oleClasses = map[string]oleClass{
+	"0002CE02-0000-0000-C000-000000000046": {"Microsoft Equation 3.0", "Equation Editor object, exploited by CVE-2017-11882 and CVE-2018-0802"},
+	"0003000C-0000-0000-C000-000000000046": {"Package", ""},
+	"F20DA720-C02F-11CE-927B-0800095AE340": {"OLE Package", ""},
+	"25336920-03F9-11CF-8FD0-00AA00686F13": {"htmlfile", "HTML document object, exploited by CVE-2017-0199"},
+	"79EAC9E0-BAF9-11CE-8C82-00AA004BA90B": {"URL Moniker", "URL moniker, loads remote content as in CVE-2017-0199"},
+	"EAB22AC3-30C1-11CF-A7EB-0000C05BAE0B": {"Shell.Explorer.1", "Web browser control, loads remote content"},
+	"00020906-0000-0000-C000-000000000046": {"Word.Document.8", ""},
+	"00020820-0000-0000-C000-000000000046": {"Excel.Sheet.8", ""},
+	"64818D10-4F9B-11CF-86EA-00AA00B929E8": {"PowerPoint.Show.8", ""},
+}
+
+// wordField matches a DDE field in the text of a word binary document, field
+// instructions run from the 0x13 begin mark to the separator or end mark
+var wordField = regexp.MustCompile(`(?i)\x13\s*(DDEAUTO|DDE)\s[^\x14\x15]*`)
+
+// analyzer gathers the results of a document and the documents embedded in it
+type analyzer struct {
+	results  *ResultsData
+	keywords *keywordSet
+}
+
+func (a *analyzer) classify(obj *Object) {
+	class, ok := oleClasses[obj.CLSID]
+	if !ok {
+		return
+	}
+	obj.Class = class.name
+	if len(class.exploit) != 0 {
+		a.keywords.add(keywordSuspicious, class.name, class.exploit)
+	}
+}
+
+// analyzeOLE lists the streams of a compound file and looks through them for
+// macros, embedded objects and fields
+func (a *analyzer) analyzeOLE(data []byte, container string, depth int) (*oleFile, error) {
+
+	f, err := openOLE(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range f.tree {
+		if entry.kind == oleRoot {
+			continue
+		}
+		stream := Stream{Container: container, Path: entry.path, Type: "stream", Size: entry.size, CLSID: entry.clsid}
+		if entry.kind == oleStorage {
+			stream.Type, stream.Size = "storage", 0
+		}
+		a.results.Streams = append(a.results.Streams, stream)
+	}
+
+	for _, macro := range extractVBA(f, container) {
+		a.results.Macros = append(a.results.Macros, macro)
+		a.keywords.scanCode(macro.Code)
+	}
+
+	for _, entry := range f.tree {
+		parent := baseName(parentPath(entry.path))
+		switch {
+		case entry.kind == oleStream && entry.name == "\x01Ole10Native":
+			a.results.Objects = append(a.results.Objects, nativeObject(f.stream(entry), container, entry.path))
+		// word keeps embedded objects in the ObjectPool storage and excel in MBD storages
+		case entry.kind == oleStorage && (strings.EqualFold(parent, "ObjectPool") || strings.HasPrefix(entry.name, "MBD")):
+			obj := Object{Container: container, Name: entry.path, Type: "ole", CLSID: entry.clsid}
+			for _, child := range f.children(entry.path) {
+				obj.Size += child.size
+			}
+			a.classify(&obj)
+			a.results.Objects = append(a.results.Objects, obj)
+		// an embedded office 2007 document is stored whole in a Package stream
+		case entry.kind == oleStream && strings.EqualFold(entry.name, "Package") && depth < maxDepth:
+			if err := a.analyzeOOXML(f.stream(entry), joinContainer(container, entry.path), depth+1); err != nil {
+				log.Debug(errors.Wrapf(err, "Error while parsing embedded package %s", entry.path))
+			}
+		}
+	}
+
+	if f.find("EncryptedPackage") != nil {
+		a.results.Encrypted = true
+	}
+	if entry := f.find("WordDocument"); entry != nil {
+		a.scanWordBinary(f.stream(entry))
+	}
+	for _, name := range []string{"Workbook", "Book"} {
+		if entry := f.find(name); entry != nil {
+			a.scanBIFF(f.stream(entry))
+		}
+	}
+
+	return f, nil
+}
+
+// scanWordBinary checks the encryption flag and looks for DDE fields in a word
+// 97-2003 document stream
+func (a *analyzer) scanWordBinary(data []byte) {
+
+	// fEncrypted in the FibBase flags
+	if len(data) >= 0x0c && binary.LittleEndian.Uint16(data[0x0a:])&0x0100 != 0 {
+		a.results.Encrypted = true
+		return
+	}
+
+	// text is stored as cp1252 or utf-16, dropping the nul bytes reads both
+	text := bytes.Replace(data, []byte{0}, nil, -1)
+	for _, match := range wordField.FindAll(text, -1) {
+		instruction := strings.TrimSpace(decodeText(bytes.TrimPrefix(match, []byte{0x13}), codePageWindows1252))
+		a.addDDE(instruction)
+	}
+}
+
+// scanBIFF looks for excel 4.0 macro sheets and the names that run them in a
+// workbook stream
+func (a *analyzer) scanBIFF(data []byte) {
+
+	for pos := 0; pos+4 <= len(data); {
+		id := binary.LittleEndian.Uint16(data[pos:])
+		size := int(binary.LittleEndian.Uint16(data[pos+2:]))
+		pos += 4
+		if size > len(data)-pos {
+			return
+		}
+		record := data[pos : pos+size]
+		pos += size
+
+		switch id {
+		// the records after FILEPASS are encrypted
+		case biffFilePass:
+			a.results.Encrypted = true
+			return
+		case biffBoundSheet:
+			if len(record) < 8 || record[5] != 1 {
+				continue
+			}
+			name := biffString(record[6:])
+			a.keywords.add(keywordXLM, name, fmt.Sprintf("Excel 4.0 macro sheet (%s)", sheetVisibility(record[4])))
+		case biffName:
+			// built in names store their index as the first character
+			if len(record) < 16 || binary.LittleEndian.Uint16(record)&0x0020 == 0 {
+				continue
+			}
+			switch record[15] {
+			case 0x01:
+				a.keywords.add(keywordAutoExec, "Auto_Open", "Excel 4.0 macros run when the workbook is opened")
+			case 0x02:
+				a.keywords.add(keywordAutoExec, "Auto_Close", "Excel 4.0 macros run when the workbook is closed")
+			}
+		}
+	}
+}
+
+// biffString reads a ShortXLUnicodeString
+func biffString(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+	length := int(data[0])
+	if data[1]&1 == 0 {
+		return decodeText(slice(data[2:], length), codePageWindows1252)
+	}
+	return decodeUTF16(slice(data[2:], 2*length))
+}
+
+func sheetVisibility(state byte) string {
+	switch state & 0x03 {
+	case 1:
+		return "hidden"
+	case 2:
+		return "very hidden"
+	}
+	return "visible"
+}
+
+func (a *analyzer) addDDE(instruction string) {
+	if len(instruction) == 0 || contains(a.results.DDE, instruction) {
+		return
+	}
+	a.results.DDE = append(a.results.DDE, instruction)
+}
+
+// nativeObject reads an Ole10Native stream, which packages a file with the
+// name and path it was embedded from
+func nativeObject(data []byte, container string, name string) Object {
+
+	obj := Object{Container: container, Name: name, Type: "native", Size: uint64(len(data))}
+
+	// total size and flags
+	pos := 6
+	label, pos, ok := cString(data, pos)
+	if !ok {
+		return obj
+	}
+	source, pos, ok := cString(data, pos)
+	if !ok {
+		return obj
+	}
+	// two unknown dwords
+	temp, pos, ok := cString(data, pos+8)
+	if !ok || pos+4 > len(data) {
+		return obj
+	}
+	size := int(binary.LittleEndian.Uint32(data[pos:]))
+	content := slice(data[pos+4:], size)
+
+	obj.Type = "package"
+	obj.FileName = label
+	obj.SourcePath = source
+	obj.TempPath = temp
+	obj.Size = uint64(len(content))
+	obj.SHA256 = sha256Hex(content)
+
+	return obj
+}
+
+// cString reads a nul terminated ansi string at pos, returning the position after it
+func cString(data []byte, pos int) (string, int, bool) {
+	if pos < 0 || pos > len(data) {
+		return "", pos, false
+	}
+	end := bytes.IndexByte(data[pos:], 0)
+	if end < 0 {
+		return "", pos, false
+	}
+	return decodeText(data[pos:pos+end], codePageWindows1252), pos + end + 1, true
+}
+
+// slice returns data[:n] clamped to data
+func slice(data []byte, n int) []byte {
+	if n < 0 {
+		return nil
+	}
+	if n > len(data) {
+		return data
+	}
+	return data[:n]
+}
+
+// joinContainer names a part or stream inside another container
+func joinContainer(container string, name string) string {
+	if len(container) == 0 {
+		return name
+	}
+	return container + "!" + name
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func contains(list []string, item string) bool {
+	for _, i := range list {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
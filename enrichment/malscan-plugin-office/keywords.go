@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// keyword types
+const (
+	keywordAutoExec   = "autoexec"
+	keywordSuspicious = "suspicious"
+	keywordIOC        = "ioc"
+	keywordXLM        = "xlm"
+)
+
+// Keyword json object, something noteworthy found in the macros
+type Keyword struct {
+	Type        string `json:"type" structs:"type"`
+	Keyword     string `json:"keyword" structs:"keyword"`
+	Description string `json:"description" structs:"description"`
+}
+
+type keywordRule struct {
+	pattern     *regexp.Regexp
+	description string
+}
+
+func rule(pattern string, description string) keywordRule {
+	return keywordRule{pattern: regexp.MustCompile(`(?i)` + pattern), description: description}
+}
+
+// autoExecRules match the procedures office runs without the user asking
+var autoExecRules = []keywordRule{
+	rule(`\bAutoExec\b`, "Runs when Word starts"),
+	rule(`\bAuto_?Open\b`, "Runs when the document or workbook is opened"),
+	rule(`\bDocument_?Open\b`, "Runs when the Word document is opened"),
+	rule(`\bWorkbook_Open\b`, "Runs when the Excel workbook is opened"),
+	rule(`\bWorkbook_Activate\b`, "Runs when the Excel workbook is activated"),
+	rule(`\bAuto_?Close\b`, "Runs when the document or workbook is closed"),
+	rule(`\bDocument_?(Close|BeforeClose)\b`, "Runs when the Word document is closed"),
+	rule(`\bWorkbook_(Close|BeforeClose|Deactivate)\b`, "Runs when the Excel workbook is closed"),
+	rule(`\b(AutoNew|Document_New|NewDocument)\b`, "Runs when a new Word document is created"),
+	rule(`\bAutoExit\b`, "Runs when Word exits"),
+	rule(`\bDocument_ContentControlOnEnter\b`, "Runs when the user enters a content control"),
+	rule(`\bPresentation_Open\b`, "Runs when the PowerPoint presentation is opened"),
+	rule(`\b\w+_(Painted|Painting|GotFocus|LostFocus|Layout|Resize|MouseHover|BeforeNavigate2|DocumentComplete|NavigateComplete2|DownloadBegin|DownloadComplete|ProgressChange|StatusTextChange|TitleChange)\b`,
+		"Runs when an ActiveX control on the document fires the event"),
+}
+
+// suspiciousRules match what macros use to download, drop and run payloads or
+// hide doing so
+var suspiciousRules = []keywordRule{
+	rule(`\bShell\b`, "May run an executable file or a system command"),
+	rule(`\bWScript\.Shell\b`, "May run an executable file or a system command"),
+	rule(`\bShell\.Application\b`, "May run an application"),
+	rule(`\bShellExecute\w*\b`, "May run an executable file or a system command"),
+	rule(`\.(Run|Exec)\b`, "May run an executable file, a system command or a macro"),
+	rule(`\b(Win32_Process|winmgmts)\b`, "May run a process through WMI"),
+	rule(`\bCreateProcess\w*\b`, "May run an executable file"),
+	rule(`\b(CreateObject|GetObject)\b`, "May create or access an OLE object"),
+	rule(`\bCallByName\b`, "May call a function by name to hide the call"),
+	rule(`\bDeclare\b[^\n]*\bLib\b`, "May run code from a DLL"),
+	rule(`\b(VirtualAlloc\w*|RtlMoveMemory|WriteProcessMemory|CreateThread|CreateRemoteThread|QueueUserAPC|EnumSystemLanguageGroupsW?)\b`,
+		"May inject code into memory"),
+	rule(`\bURLDownloadToFile\w*\b`, "May download files from the internet"),
+	rule(`\b(Microsoft\.XMLHTTP|MSXML2\.\w*XMLHTTP\w*|WinHttp\.WinHttpRequest\w*|InternetOpen\w*|InternetReadFile)\b`,
+		"May download files from the internet"),
+	rule(`\bADODB\.Stream\b`, "May write binary data to a file"),
+	rule(`\bSaveToFile\b`, "May write binary data to a file"),
+	rule(`\b(CreateTextFile|Scripting\.FileSystemObject)\b`, "May create a file"),
+	rule(`\bOpen\b[^\n]*\bFor\s+(Binary|Output|Append)\b`, "May open a file for writing"),
+	rule(`\b(Put|Print|Write)\s+#`, "May write to a file"),
+	rule(`\b(FileCopy|CopyFile|CopyHere)\b`, "May copy a file"),
+	rule(`\b(Kill|DeleteFile)\b`, "May delete a file"),
+	rule(`\bEnviron\b`, "May read environment variables"),
+	rule(`\b(RegWrite|RegRead|RegDelete)\b`, "May access the registry"),
+	rule(`\bSendKeys\b`, "May control another application by simulating keystrokes"),
+	rule(`\b(VBProject|VBComponents|CodeModule|AddFromString|AddFromFile)\b`, "May read or modify the VBA code itself"),
+	rule(`\bExecuteExcel4Macro\b`, "May run an Excel 4.0 macro"),
+	rule(`\bChr[BW]?\$?\s*\(`, "May build strings from character codes to hide them"),
+	rule(`\bStrReverse\b`, "May reverse strings to hide them"),
+	rule(`\bXor\b`, "May decode data with xor"),
+	rule(`\b(Base64\w*|FromBase64String)\b`, "May decode base64 data"),
+	rule(`\b(powershell|pwsh)(\.exe)?\b`, "May run PowerShell commands"),
+	rule(`\bcmd(\.exe)?\s+/[ck]\b`, "May run a command through cmd.exe"),
+	rule(`\b((mshta|rundll32|regsvr32|certutil|bitsadmin|schtasks|wmic|msiexec)(\.exe)?|(cscript|wscript)\.exe)\b`,
+		"May run a system binary often abused to run or download payloads"),
+}
+
+var (
+	urlPattern        = regexp.MustCompile(`(?i)\b(https?|ftp)://[^\s"'<>()]+`)
+	ipPattern         = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	executablePattern = regexp.MustCompile(`(?i)\b[\w-]+\.(exe|dll|scr|pif|bat|cmd|ps1|vbs|vbe|jse?|hta|wsf|lnk|jar)\b`)
+)
+
+// keywordSet collects keywords once each
+type keywordSet struct {
+	keywords []Keyword
+	seen     map[string]bool
+}
+
+func newKeywordSet() *keywordSet {
+	return &keywordSet{keywords: []Keyword{}, seen: map[string]bool{}}
+}
+
+func (s *keywordSet) add(kind string, keyword string, description string) {
+	key := kind + "\x00" + strings.ToLower(keyword)
+	if s.seen[key] {
+		return
+	}
+	s.seen[key] = true
+	s.keywords = append(s.keywords, Keyword{Type: kind, Keyword: keyword, Description: description})
+}
+
+func (s *keywordSet) has(kind string) bool {
+	for _, keyword := range s.keywords {
+		if keyword.Type == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// scanCode flags the auto-exec procedures, suspicious calls and indicators in
+// a macro's source
+func (s *keywordSet) scanCode(code string) {
+
+	for _, r := range autoExecRules {
+		for _, match := range r.pattern.FindAllString(code, -1) {
+			s.add(keywordAutoExec, match, r.description)
+		}
+	}
+	for _, r := range suspiciousRules {
+		for _, match := range r.pattern.FindAllString(code, -1) {
+			s.add(keywordSuspicious, strings.TrimRight(match, " \t(#"), r.description)
+		}
+	}
+
+	for _, match := range urlPattern.FindAllString(code, -1) {
+		s.add(keywordIOC, match, "URL")
+	}
+	for _, match := range ipPattern.FindAllString(code, -1) {
+		if ip := net.ParseIP(match); ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
+			s.add(keywordIOC, match, "IPv4 address")
+		}
+	}
+	for _, match := range executablePattern.FindAllString(code, -1) {
+		s.add(keywordIOC, match, "Executable file name")
+	}
+}
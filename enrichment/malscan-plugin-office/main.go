@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/LiamHellend/malscan-plugin-office/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+const (
+	name     = "office"
+	category = "enricher"
+)
+
+var (
+	path string
+)
+
+// Office json object (this is what gets output)
+type Office struct {
+	Results ResultsData `json:"analysis" structs:"analysis"`
+}
+
+// ResultsData json object
+type ResultsData struct {
+	Format                string
+	Encrypted             bool
+	HasMacros             bool
+	AutoExec              bool
+	Suspicious            bool
+	XLM                   bool
+	TemplateInjection     bool
+	DDE                   []string
+	Keywords              []Keyword
+	Macros                []Macro
+	Objects               []Object
+	ExternalRelationships []Relationship
+	Streams               []Stream
+	Error                 string `json:"error" structs:"error"`
+}
+
+// AvScan performs the office document analysis, giving up after timeout seconds
+func AvScan(timeout int) Office {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	done := make(chan ResultsData, 1)
+	go func() {
+		done <- analyzeOffice(path)
+	}()
+
+	select {
+	case results := <-done:
+		return Office{Results: results}
+	case <-ctx.Done():
+		return Office{Results: ResultsData{Error: fmt.Sprintf("analysis of %s timed out", path)}}
+	}
+}
+
+// analyzeOffice parses an OLE2 compound file or OOXML package, extracting its
+// macros, embedded objects, external relationships and DDE fields
+func analyzeOffice(file string) ResultsData {
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while reading file"))
+		return ResultsData{Error: err.Error()}
+	}
+
+	results := ResultsData{
+		DDE:                   []string{},
+		Macros:                []Macro{},
+		Objects:               []Object{},
+		ExternalRelationships: []Relationship{},
+		Streams:               []Stream{},
+		Error:                 "nil",
+	}
+	a := &analyzer{results: &results, keywords: newKeywordSet()}
+
+	switch {
+	case isOLE(data):
+		results.Format = "ole"
+		_, err = a.analyzeOLE(data, "", 0)
+	case isZip(data):
+		results.Format = "ooxml"
+		err = a.analyzeOOXML(data, "", 0)
+	default:
+		err = errors.New("not an OLE2 compound file or OOXML package")
+	}
+	if err != nil {
+		log.Debug(errors.Wrap(err, "Error while parsing document"))
+		results.Error = err.Error()
+	}
+
+	results.Keywords = a.keywords.keywords
+	results.HasMacros = len(results.Macros) != 0
+	results.AutoExec = a.keywords.has(keywordAutoExec)
+	results.Suspicious = a.keywords.has(keywordSuspicious)
+	results.XLM = a.keywords.has(keywordXLM)
+
+	return results
+}
+
+func main() {
+
+	cli.AppHelpTemplate = utils.AppHelpTemplate
+	app := cli.NewApp()
+
+	app.Name = "Office"
+	app.Usage = "Malscan office plugin"
+	app.Version = "1.0.0"
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:  "debug, d",
+			Usage: "debug output",
+		},
+		cli.IntFlag{
+			Name:   "timeout",
+			Value:  900,
+			Usage:  "malcan plugin timeout (in seconds)",
+			EnvVar: "MALSCAN_TIMEOUT",
+		},
+	}
+	app.Action = func(c *cli.Context) error {
+
+		if c.Bool("debug") {
+			log.SetLevel(log.DebugLevel)
+		}
+
+		if c.Args().Present() {
+
+			path, _ = filepath.Abs(c.Args().First())
+
+			office := AvScan(c.Int("timeout"))
+
+			// convert to JSON
+			officeJSON, _ := json.Marshal(office)
+
+			fmt.Println(string(officeJSON))
+
+		}
+
+		return nil
+	}
+
+	app.Run(os.Args)
+
+}
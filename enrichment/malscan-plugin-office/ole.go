@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// compound file special sector numbers and directory entry types
+const (
+	oleMaxSector  = 0xfffffffa
+	oleNoStream   = 0xffffffff
+	oleStorage    = 1
+	oleStream     = 2
+	oleRoot       = 5
+	oleEntrySize  = 128
+	oleMaxEntries = 1 << 16
+)
+
+var oleMagic = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+
+// oleEntry is a storage or stream of a compound file, path joins the storage
+// names above it with slashes
+type oleEntry struct {
+	name  string
+	path  string
+	kind  byte
+	clsid string
+	start uint32
+	size  uint64
+	left  uint32
+	right uint32
+	child uint32
+}
+
+// oleFile is a parsed compound file (MS-CFB)
+type oleFile struct {
+	data       []byte
+	sectorSize int
+	miniSize   int
+	cutoff     uint64
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	entries    []*oleEntry
+	// tree lists the entries reachable from the root, parents before children
+	tree []*oleEntry
+}
+
+func isOLE(data []byte) bool {
+	return bytes.HasPrefix(data, oleMagic)
+}
+
+// openOLE reads the allocation tables and directory of a compound file
+func openOLE(data []byte) (*oleFile, error) {
+
+	if len(data) < 512 || !isOLE(data) {
+		return nil, errors.New("not an OLE2 compound file")
+	}
+
+	shift := binary.LittleEndian.Uint16(data[0x1e:])
+	if shift != 9 && shift != 12 {
+		return nil, errors.Errorf("invalid sector shift %d", shift)
+	}
+	miniShift := binary.LittleEndian.Uint16(data[0x20:])
+	if miniShift == 0 || miniShift >= shift {
+		return nil, errors.Errorf("invalid mini sector shift %d", miniShift)
+	}
+
+	f := &oleFile{
+		data:       data,
+		sectorSize: 1 << shift,
+		miniSize:   1 << miniShift,
+		cutoff:     uint64(binary.LittleEndian.Uint32(data[0x38:])),
+	}
+
+	// the first 109 fat sectors are listed in the header, the rest in a chain of
+	// difat sectors whose last entry points to the next one
+	var difat []uint32
+	for i := 0; i < 109; i++ {
+		difat = append(difat, binary.LittleEndian.Uint32(data[0x4c+4*i:]))
+	}
+	next := binary.LittleEndian.Uint32(data[0x44:])
+	seen := map[uint32]bool{}
+	for next < oleMaxSector && !seen[next] {
+		seen[next] = true
+		sector := f.sector(next)
+		if len(sector) < f.sectorSize {
+			break
+		}
+		last := f.sectorSize/4 - 1
+		for i := 0; i < last; i++ {
+			difat = append(difat, binary.LittleEndian.Uint32(sector[4*i:]))
+		}
+		next = binary.LittleEndian.Uint32(sector[4*last:])
+	}
+
+	// each fat sector is read once, and no more of them than the header counts
+	// or the file can hold
+	fatSectors := int(binary.LittleEndian.Uint32(data[0x2c:]))
+	if limit := len(data) / f.sectorSize; fatSectors > limit {
+		fatSectors = limit
+	}
+	fatSeen := map[uint32]bool{}
+	for _, id := range difat {
+		if len(fatSeen) >= fatSectors {
+			break
+		}
+		if id >= oleMaxSector || fatSeen[id] {
+			continue
+		}
+		fatSeen[id] = true
+		f.fat = append(f.fat, uint32s(f.sector(id))...)
+	}
+
+	directory := f.readChain(binary.LittleEndian.Uint32(data[0x30:]), ^uint64(0), false)
+	for i := 0; i+oleEntrySize <= len(directory) && i/oleEntrySize < oleMaxEntries; i += oleEntrySize {
+		f.entries = append(f.entries, parseEntry(directory[i:i+oleEntrySize], f.sectorSize == 512))
+	}
+	if len(f.entries) == 0 || f.entries[0].kind != oleRoot {
+		return nil, errors.New("compound file has no root entry")
+	}
+
+	root := f.entries[0]
+	f.miniStream = f.readChain(root.start, root.size, false)
+	f.miniFAT = uint32s(f.readChain(binary.LittleEndian.Uint32(data[0x3c:]), ^uint64(0), false))
+
+	root.path = ""
+	f.tree = append(f.tree, root)
+	f.walk(root.child, "", map[uint32]bool{0: true})
+
+	return f, nil
+}
+
+func parseEntry(raw []byte, version3 bool) *oleEntry {
+
+	nameLength := int(binary.LittleEndian.Uint16(raw[64:]))
+	if nameLength > 64 {
+		nameLength = 64
+	}
+	var name []uint16
+	for i := 0; i+1 < nameLength; i += 2 {
+		c := binary.LittleEndian.Uint16(raw[i:])
+		if c == 0 {
+			break
+		}
+		name = append(name, c)
+	}
+
+	size := binary.LittleEndian.Uint64(raw[120:])
+	// version 3 files only use the low half of the size
+	if version3 {
+		size &= 0xffffffff
+	}
+
+	return &oleEntry{
+		name:  string(utf16.Decode(name)),
+		kind:  raw[66],
+		left:  binary.LittleEndian.Uint32(raw[68:]),
+		right: binary.LittleEndian.Uint32(raw[72:]),
+		child: binary.LittleEndian.Uint32(raw[76:]),
+		clsid: formatCLSID(raw[80:96]),
+		start: binary.LittleEndian.Uint32(raw[116:]),
+		size:  size,
+	}
+}
+
+// walk visits the red-black tree of a storage's children in name order
+func (f *oleFile) walk(index uint32, parent string, seen map[uint32]bool) {
+
+	if index == oleNoStream || int(index) >= len(f.entries) || seen[index] {
+		return
+	}
+	seen[index] = true
+	entry := f.entries[index]
+
+	f.walk(entry.left, parent, seen)
+
+	entry.path = entry.name
+	if len(parent) != 0 {
+		entry.path = parent + "/" + entry.name
+	}
+	f.tree = append(f.tree, entry)
+	if entry.kind == oleStorage {
+		f.walk(entry.child, entry.path, seen)
+	}
+
+	f.walk(entry.right, parent, seen)
+}
+
+// sector returns a regular sector, shorter when the file is truncated
+func (f *oleFile) sector(id uint32) []byte {
+	offset := (uint64(id) + 1) * uint64(f.sectorSize)
+	if offset >= uint64(len(f.data)) {
+		return nil
+	}
+	end := offset + uint64(f.sectorSize)
+	if end > uint64(len(f.data)) {
+		end = uint64(len(f.data))
+	}
+	return f.data[offset:end]
+}
+
+// readChain follows a sector chain in the fat or mini fat for up to size bytes
+func (f *oleFile) readChain(start uint32, size uint64, mini bool) []byte {
+
+	table, sectorSize := f.fat, f.sectorSize
+	if mini {
+		table, sectorSize = f.miniFAT, f.miniSize
+	}
+
+	var data []byte
+	seen := map[uint32]bool{}
+	for id := start; id < oleMaxSector && !seen[id] && uint64(len(data)) < size; {
+		seen[id] = true
+
+		var chunk []byte
+		if mini {
+			offset := uint64(id) * uint64(sectorSize)
+			if offset >= uint64(len(f.miniStream)) {
+				break
+			}
+			end := offset + uint64(sectorSize)
+			if end > uint64(len(f.miniStream)) {
+				end = uint64(len(f.miniStream))
+			}
+			chunk = f.miniStream[offset:end]
+		} else {
+			chunk = f.sector(id)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		data = append(data, chunk...)
+
+		if int(id) >= len(table) {
+			break
+		}
+		id = table[id]
+	}
+
+	if uint64(len(data)) > size {
+		data = data[:size]
+	}
+
+	return data
+}
+
+// stream returns the contents of a stream entry
+func (f *oleFile) stream(entry *oleEntry) []byte {
+	if entry.kind != oleStream {
+		return nil
+	}
+	return f.readChain(entry.start, entry.size, entry.size < f.cutoff)
+}
+
+// find returns the entry at path, compared case insensitively as office does
+func (f *oleFile) find(path string) *oleEntry {
+	for _, entry := range f.tree {
+		if strings.EqualFold(entry.path, path) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// children returns the entries directly inside the storage at path
+func (f *oleFile) children(path string) []*oleEntry {
+	var children []*oleEntry
+	for _, entry := range f.tree {
+		if entry.kind != oleRoot && strings.EqualFold(parentPath(entry.path), path) {
+			children = append(children, entry)
+		}
+	}
+	return children
+}
+
+func parentPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+func baseName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func uint32s(data []byte) []uint32 {
+	values := make([]uint32, 0, len(data)/4)
+	for i := 0; i+4 <= len(data); i += 4 {
+		values = append(values, binary.LittleEndian.Uint32(data[i:]))
+	}
+	return values
+}
+
+// formatCLSID formats a little endian guid, or returns an empty string for a null one
+func formatCLSID(raw []byte) string {
+	if len(raw) < 16 || bytes.Equal(raw[:16], make([]byte, 16)) {
+		return ""
+	}
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X",
+		binary.LittleEndian.Uint32(raw), binary.LittleEndian.Uint16(raw[4:]), binary.LittleEndian.Uint16(raw[6:]), raw[8:10], raw[10:16])
+}
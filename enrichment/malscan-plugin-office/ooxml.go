@@ -0,0 +1,356 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxPartSize skips package parts that would decompress to more than this
+const maxPartSize = 64 << 20
+
+// Relationship json object, a package relationship pointing outside the document
+type Relationship struct {
+	Container string `json:"container,omitempty" structs:"container,omitempty"`
+	Part      string `json:"part" structs:"part"`
+	Type      string `json:"type" structs:"type"`
+	Target    string `json:"target" structs:"target"`
+	// Injection is set for relationships that make office load remote content
+	// when the document opens
+	Injection bool `json:"injection" structs:"injection"`
+}
+
+// injectionTypes are the external relationship types abused to load remote
+// templates, frames and objects
+var injectionTypes = map[string]bool{
+	"attachedTemplate": true,
+	"frame":            true,
+	"subDocument":      true,
+	"oleObject":        true,
+}
+
+var (
+	// ddeFormula matches a DDE formula in a worksheet cell, as in cmd|'/c calc'!A0
+	ddeFormula  = regexp.MustCompile(`^\s*=?\s*[\w.-]+\s*\|\s*'[^']*'\s*!`)
+	activeClass = regexp.MustCompile(`classid="\{([0-9A-Fa-f-]+)\}"`)
+)
+
+type relationships struct {
+	Relationships []struct {
+		Type       string `xml:"Type,attr"`
+		Target     string `xml:"Target,attr"`
+		TargetMode string `xml:"TargetMode,attr"`
+	} `xml:"Relationship"`
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// analyzeOOXML looks through the parts of an office open xml package for vba
+// projects, embedded objects, external relationships and fields
+func (a *analyzer) analyzeOOXML(data []byte, container string, depth int) error {
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return errors.Wrap(err, "not an OOXML package")
+	}
+
+	for _, file := range r.File {
+		name := file.Name
+		lower := strings.ToLower(name)
+		part := joinContainer(container, name)
+
+		switch {
+		case strings.HasSuffix(lower, ".rels"):
+			a.relationships(file, container)
+		case baseName(lower) == "vbaproject.bin":
+			content, err := readPart(file)
+			if err != nil {
+				log.Debug(err)
+				continue
+			}
+			if _, err := a.analyzeOLE(content, part, depth+1); err != nil {
+				log.Debug(errors.Wrapf(err, "Error while parsing vba project %s", name))
+			}
+		case strings.Contains(lower, "/embeddings/"):
+			a.embedded(file, container, depth)
+		case strings.Contains(lower, "/activex/") && strings.HasSuffix(lower, ".xml"):
+			content, err := readPart(file)
+			if err != nil {
+				log.Debug(err)
+				continue
+			}
+			obj := Object{Container: container, Name: name, Type: "activex", Size: uint64(len(content))}
+			if match := activeClass.FindSubmatch(content); match != nil {
+				obj.CLSID = strings.ToUpper(string(match[1]))
+			}
+			a.classify(&obj)
+			a.results.Objects = append(a.results.Objects, obj)
+		case strings.HasPrefix(lower, "xl/macrosheets/") && strings.HasSuffix(lower, ".xml"):
+			a.keywords.add(keywordXLM, baseName(name), "Excel 4.0 macro sheet")
+		case lower == "xl/workbook.xml":
+			a.definedNames(file)
+		case strings.HasPrefix(lower, "xl/externallinks/") && strings.HasSuffix(lower, ".xml"):
+			a.ddeLinks(file)
+		case strings.HasPrefix(lower, "xl/worksheets/") && strings.HasSuffix(lower, ".xml"):
+			a.ddeFormulas(file)
+		case strings.HasPrefix(lower, "word/") && strings.HasSuffix(lower, ".xml"):
+			a.wordFields(file)
+		}
+	}
+
+	return nil
+}
+
+// relationships records the external targets of a relationships part
+func (a *analyzer) relationships(file *zip.File, container string) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	var rels relationships
+	if err := xml.Unmarshal(content, &rels); err != nil {
+		log.Debug(errors.Wrapf(err, "Error while parsing relationships %s", file.Name))
+		return
+	}
+
+	for _, rel := range rels.Relationships {
+		if !strings.EqualFold(rel.TargetMode, "External") {
+			continue
+		}
+		kind := baseName(rel.Type)
+		relationship := Relationship{
+			Container: container,
+			Part:      file.Name,
+			Type:      kind,
+			Target:    rel.Target,
+			Injection: injectionTypes[kind],
+		}
+		if relationship.Injection {
+			a.results.TemplateInjection = true
+		}
+		a.results.ExternalRelationships = append(a.results.ExternalRelationships, relationship)
+	}
+}
+
+// embedded lists an embedded part, following it when it is itself a document
+func (a *analyzer) embedded(file *zip.File, container string, depth int) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	obj := Object{Container: container, Name: file.Name, Type: "file", Size: uint64(len(content)), SHA256: sha256Hex(content)}
+	part := joinContainer(container, file.Name)
+
+	switch {
+	case depth >= maxDepth:
+	case isOLE(content):
+		obj.Type = "ole"
+		f, err := a.analyzeOLE(content, part, depth+1)
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while parsing embedded object %s", file.Name))
+			break
+		}
+		// the root entry holds the class of the embedded object
+		obj.CLSID = f.entries[0].clsid
+		a.classify(&obj)
+	case isZip(content):
+		obj.Type = "document"
+		if err := a.analyzeOOXML(content, part, depth+1); err != nil {
+			log.Debug(errors.Wrapf(err, "Error while parsing embedded document %s", file.Name))
+		}
+	}
+
+	a.results.Objects = append(a.results.Objects, obj)
+}
+
+// wordFields reads the field instructions of a word part, from simple fields
+// and from the instrText runs between complex field begin and end marks
+func (a *analyzer) wordFields(file *zip.File) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	var instructions []string
+	var instruction strings.Builder
+	nesting := 0
+	inText := false
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			if err != io.EOF {
+				log.Debug(errors.Wrapf(err, "Error while parsing %s", file.Name))
+			}
+			break
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "fldSimple":
+				instructions = append(instructions, attribute(t, "instr"))
+			case "instrText":
+				inText = true
+			case "fldChar":
+				switch attribute(t, "fldCharType") {
+				case "begin":
+					nesting++
+				// nested fields build the instruction of the outer one, it ends
+				// at the outer field's separator or end mark
+				case "separate", "end":
+					if nesting == 1 && instruction.Len() != 0 {
+						instructions = append(instructions, instruction.String())
+						instruction.Reset()
+					}
+					if attribute(t, "fldCharType") == "end" && nesting > 0 {
+						nesting--
+					}
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "instrText" {
+				inText = false
+			}
+		case xml.CharData:
+			if inText && nesting > 0 {
+				instruction.Write(t)
+			}
+		}
+	}
+
+	for _, instruction := range instructions {
+		instruction = strings.TrimSpace(instruction)
+		fields := strings.Fields(instruction)
+		if len(fields) != 0 && (strings.EqualFold(fields[0], "DDE") || strings.EqualFold(fields[0], "DDEAUTO")) {
+			a.addDDE(instruction)
+		}
+	}
+}
+
+// ddeLinks reads the dde links of an excel external link part
+func (a *analyzer) ddeLinks(file *zip.File) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if t, ok := token.(xml.StartElement); ok && t.Name.Local == "ddeLink" {
+			a.addDDE(attribute(t, "ddeService") + "|" + attribute(t, "ddeTopic"))
+		}
+	}
+}
+
+// ddeFormulas looks for cell formulas that call a dde server
+func (a *analyzer) ddeFormulas(file *zip.File) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	inFormula := false
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			inFormula = t.Name.Local == "f"
+		case xml.EndElement:
+			inFormula = false
+		case xml.CharData:
+			if inFormula && ddeFormula.Match(t) {
+				a.addDDE(strings.TrimSpace(string(t)))
+			}
+		}
+	}
+}
+
+// definedNames flags the Auto_Open names that run excel 4.0 macros
+func (a *analyzer) definedNames(file *zip.File) {
+
+	content, err := readPart(file)
+	if err != nil {
+		log.Debug(err)
+		return
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		t, ok := token.(xml.StartElement)
+		if !ok || t.Name.Local != "definedName" {
+			continue
+		}
+		name := strings.TrimPrefix(attribute(t, "name"), "_xlnm.")
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "auto_open"):
+			a.keywords.add(keywordAutoExec, name, "Excel 4.0 macros run when the workbook is opened")
+		case strings.HasPrefix(lower, "auto_close"):
+			a.keywords.add(keywordAutoExec, name, "Excel 4.0 macros run when the workbook is closed")
+		}
+	}
+}
+
+// readPart decompresses a package part, refusing parts larger than maxPartSize
+func readPart(file *zip.File) ([]byte, error) {
+
+	rc, err := file.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error while opening part %s", file.Name)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxPartSize+1))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error while reading part %s", file.Name)
+	}
+	if len(content) > maxPartSize {
+		return nil, errors.Errorf("part %s is larger than %d bytes", file.Name, maxPartSize)
+	}
+
+	return content, nil
+}
+
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
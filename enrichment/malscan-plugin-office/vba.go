@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// dir stream record ids (MS-OVBA 2.3.4.2)
+const (
+	recordCodePage          = 0x0003
+	recordProjectVersion    = 0x0009
+	recordModuleName        = 0x0019
+	recordModuleStreamName  = 0x001a
+	recordModuleType        = 0x0021
+	recordModuleClass       = 0x0022
+	recordModuleTerminator  = 0x002b
+	recordModuleOffset      = 0x0031
+	recordModuleStreamNameW = 0x0032
+	recordModuleNameW       = 0x0047
+)
+
+const (
+	compressedChunkSize = 4096
+	codePageWindows1252 = 1252
+)
+
+// Macro json object, the source of one vba module
+type Macro struct {
+	// Container is the package part holding the vba project, empty for ole files
+	Container string `json:"container,omitempty" structs:"container,omitempty"`
+	Project   string `json:"project" structs:"project"`
+	Module    string `json:"module" structs:"module"`
+	Stream    string `json:"stream" structs:"stream"`
+	Type      string `json:"type" structs:"type"`
+	Code      string `json:"code" structs:"code"`
+}
+
+type vbaModule struct {
+	name   string
+	stream string
+	kind   string
+	offset uint32
+}
+
+// extractVBA returns the modules of every vba project in the compound file,
+// projects live in a VBA storage next to their dir stream
+func extractVBA(f *oleFile, container string) []Macro {
+
+	macros := []Macro{}
+	for _, entry := range f.tree {
+		if entry.kind != oleStream || !strings.EqualFold(entry.name, "dir") || !strings.EqualFold(baseName(parentPath(entry.path)), "VBA") {
+			continue
+		}
+		project := parentPath(entry.path)
+
+		found, err := projectModules(f, project, entry, container)
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while parsing vba project %s", project))
+		}
+		// a damaged or tampered dir stream should not hide the source
+		if len(found) == 0 {
+			found = scanModules(f, project, container)
+		}
+		macros = append(macros, found...)
+	}
+
+	return macros
+}
+
+// projectModules reads the module list from the dir stream and decompresses
+// each module's source
+func projectModules(f *oleFile, project string, dir *oleEntry, container string) ([]Macro, error) {
+
+	data, err := decompress(f.stream(dir))
+	if err != nil {
+		return nil, errors.Wrap(err, "dir stream")
+	}
+
+	codePage := uint16(codePageWindows1252)
+	var modules []vbaModule
+	var current *vbaModule
+
+	for pos := 0; pos+6 <= len(data); {
+		id := binary.LittleEndian.Uint16(data[pos:])
+		size := int(binary.LittleEndian.Uint32(data[pos+2:]))
+		pos += 6
+		// the version record's size field is fixed at 4 but 6 bytes follow it
+		if id == recordProjectVersion {
+			size = 6
+		}
+		if size < 0 || size > len(data)-pos {
+			return nil, errors.Errorf("record 0x%04x overruns the dir stream", id)
+		}
+		value := data[pos : pos+size]
+		pos += size
+
+		if id == recordCodePage && size >= 2 {
+			codePage = binary.LittleEndian.Uint16(value)
+			continue
+		}
+		if id == recordModuleName {
+			current = &vbaModule{name: decodeText(value, codePage)}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch id {
+		case recordModuleNameW:
+			current.name = decodeUTF16(value)
+		case recordModuleStreamName:
+			current.stream = decodeText(value, codePage)
+		case recordModuleStreamNameW:
+			current.stream = decodeUTF16(value)
+		case recordModuleOffset:
+			if size >= 4 {
+				current.offset = binary.LittleEndian.Uint32(value)
+			}
+		case recordModuleType:
+			current.kind = "standard"
+		case recordModuleClass:
+			current.kind = "class"
+		case recordModuleTerminator:
+			modules = append(modules, *current)
+			current = nil
+		}
+	}
+
+	macros := []Macro{}
+	for _, module := range modules {
+		streamName := module.stream
+		if len(streamName) == 0 {
+			streamName = module.name
+		}
+		entry := f.find(project + "/" + streamName)
+		if entry == nil {
+			log.Debugf("vba module stream %s not found", streamName)
+			continue
+		}
+		data := f.stream(entry)
+		if int(module.offset) >= len(data) {
+			log.Debugf("vba module %s source offset past the end of the stream", module.name)
+			continue
+		}
+		source, err := decompress(data[module.offset:])
+		if err != nil {
+			log.Debug(errors.Wrapf(err, "Error while decompressing vba module %s", module.name))
+		}
+		macros = append(macros, Macro{
+			Container: container,
+			Project:   project,
+			Module:    module.name,
+			Stream:    entry.path,
+			Type:      module.kind,
+			Code:      normalizeSource(decodeText(source, codePage)),
+		})
+	}
+
+	return macros, nil
+}
+
+// scanModules looks for compressed source in every stream of the project by
+// the Attribute lines the vba editor writes at the top of each module
+func scanModules(f *oleFile, project string, container string) []Macro {
+
+	macros := []Macro{}
+	for _, entry := range f.children(project) {
+		if entry.kind != oleStream || strings.EqualFold(entry.name, "dir") || strings.HasPrefix(strings.ToLower(entry.name), "_vba_project") {
+			continue
+		}
+		data := f.stream(entry)
+		// the container signature and chunk header come right before the
+		// flag byte of the first token sequence
+		i := bytes.Index(data, []byte("\x00Attribut"))
+		if i < 3 || data[i-3] != 1 {
+			continue
+		}
+		source, err := decompress(data[i-3:])
+		if len(source) == 0 {
+			log.Debug(errors.Wrapf(err, "Error while decompressing stream %s", entry.path))
+			continue
+		}
+		macros = append(macros, Macro{
+			Container: container,
+			Project:   project,
+			Module:    entry.name,
+			Stream:    entry.path,
+			Code:      normalizeSource(decodeText(source, codePageWindows1252)),
+		})
+	}
+
+	return macros
+}
+
+// decompress expands an MS-OVBA compressed container, returning what it could
+// decompress along with any error
+func decompress(data []byte) ([]byte, error) {
+
+	if len(data) == 0 || data[0] != 1 {
+		return nil, errors.New("invalid compressed container signature")
+	}
+
+	var out []byte
+	for pos := 1; pos+2 <= len(data); {
+		header := binary.LittleEndian.Uint16(data[pos:])
+		end := pos + int(header&0x0fff) + 3
+		if end > len(data) {
+			end = len(data)
+		}
+		pos += 2
+
+		if header&0x8000 == 0 {
+			raw := end
+			if pos+compressedChunkSize < raw {
+				raw = pos + compressedChunkSize
+			}
+			out = append(out, data[pos:raw]...)
+			pos = end
+			continue
+		}
+
+		chunkStart := len(out)
+		for pos < end {
+			flags := data[pos]
+			pos++
+			for bit := uint(0); bit < 8 && pos < end; bit++ {
+				if flags&(1<<bit) == 0 {
+					out = append(out, data[pos])
+					pos++
+					continue
+				}
+				if pos+2 > end {
+					return out, errors.New("truncated copy token")
+				}
+				token := binary.LittleEndian.Uint16(data[pos:])
+				pos += 2
+
+				// the split between offset and length bits depends on how much of
+				// the chunk has been decompressed so far
+				bitCount := uint(4)
+				for 1<<bitCount < len(out)-chunkStart {
+					bitCount++
+				}
+				lengthMask := uint16(0xffff) >> bitCount
+				length := int(token&lengthMask) + 3
+				offset := int(token>>(16-bitCount)) + 1
+
+				source := len(out) - offset
+				if source < chunkStart {
+					return out, errors.New("copy token points before the chunk")
+				}
+				// the copy may overlap the bytes it produces
+				for i := 0; i < length; i++ {
+					out = append(out, out[source+i])
+				}
+			}
+		}
+		pos = end
+	}
+
+	return out, nil
+}
+
+// cp1252 maps the bytes windows-1252 places where latin-1 has control codes
+var cp1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// decodeText converts text in the project's code page to utf-8, code pages other
+// than windows-1252 are kept when they are already utf-8 and read as latin-1 otherwise
+func decodeText(data []byte, codePage uint16) string {
+
+	if codePage == 65001 || (codePage != codePageWindows1252 && utf8.Valid(data)) {
+		return string(data)
+	}
+
+	var b strings.Builder
+	for _, c := range data {
+		switch {
+		case c >= 0x80 && c < 0xa0 && codePage == codePageWindows1252:
+			b.WriteRune(cp1252[c-0x80])
+		default:
+			b.WriteRune(rune(c))
+		}
+	}
+
+	return b.String()
+}
+
+func decodeUTF16(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, binary.LittleEndian.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func normalizeSource(source string) string {
+	return strings.Replace(source, "\r\n", "\n", -1)
+}